@@ -0,0 +1,474 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultBenchmarkCount - The number of runs of each benchmark, when BenchmarkOptions.Count is not set
+// At least 6 samples per side are needed by the Mann-Whitney U test to get a p-value below 0.05
+const defaultBenchmarkCount = 6
+
+// defaultBenchmarkAlpha - The significance level used by CompareBenchmarks, when BenchmarkThreshold.Alpha is not set
+const defaultBenchmarkAlpha = 0.05
+
+// BenchmarkOptions - The options of RunBenchmarks
+type BenchmarkOptions struct {
+	// Only run the benchmarks matching the pattern, passed via '-bench', '.' if empty
+	Bench string
+	// Number of runs of each benchmark, passed via '-count', 6 if '0'
+	Count int
+	// Run time of each benchmark, passed via '-benchtime', like '1s' or '100x', may be empty
+	BenchTime string
+	// Build tags passed to the command via '-tags', may be empty
+	Tags []string
+	// Additional environment variables in the form 'key=value', may be empty
+	Env []string
+}
+
+// BenchmarkSample - The measurement of one run of a benchmark
+type BenchmarkSample struct {
+	Iterations  int64
+	NsPerOp     float64
+	BytesPerOp  float64
+	AllocsPerOp float64
+}
+
+// BenchmarkResult - All runs of one benchmark
+type BenchmarkResult struct {
+	// The import path of the package, or the package directory if 'go test' did not print it
+	Package string
+	// The name of the benchmark including the GOMAXPROCS suffix, like 'BenchmarkAdd-8'
+	Name    string
+	Samples []BenchmarkSample
+}
+
+// BenchmarkThreshold - The limits checked by CompareBenchmarks
+type BenchmarkThreshold struct {
+	// The maximal increase of the median of any metric compared to the baseline in percent
+	// With '0' any statistically significant increase is a regression
+	MaxRegressionPercent float64
+	// The significance level of the Mann-Whitney U test, 0.05 if '0'
+	Alpha float64
+}
+
+// BenchmarkComparison - One metric of a benchmark compared to the baseline
+type BenchmarkComparison struct {
+	Package string
+	Name    string
+	// The unit of the metric, 'ns/op', 'B/op' or 'allocs/op'
+	Unit           string
+	BaselineMedian float64
+	Median         float64
+	DeltaPercent   float64
+	// The p-value of the two sided Mann-Whitney U test
+	PValue float64
+	// Tell if the difference to the baseline is statistically significant
+	Significant bool
+	// Tell if the metric increased more than the threshold allows
+	Regression bool
+}
+
+type BenchmarkRegression struct {
+	err         string
+	regressions []BenchmarkComparison
+}
+
+func (e *BenchmarkRegression) Error() string { // Implement the Error Interface for the BenchmarkRegression struct
+	return fmt.Sprintf("Error: %s", e.err)
+}
+
+// Regressions - Get the metrics that regressed
+func (e *BenchmarkRegression) Regressions() []BenchmarkComparison {
+	return e.regressions
+}
+
+// NewBenchmarkRegression - Get a new BenchmarkRegression struct
+func NewBenchmarkRegression(regressions []BenchmarkComparison) *BenchmarkRegression {
+	names := []string{}
+	for _, regression := range regressions {
+		names = append(names, fmt.Sprintf("%s %s", regression.Name, regression.Unit))
+	}
+	return &BenchmarkRegression{fmt.Sprintf("The benchmarks \"%s\" regressed", strings.Join(names, "\", \"")), regressions}
+}
+
+// RunBenchmarks - Runs 'go test -run ^$ -bench <pattern> -benchmem -count <count>' for all given packages
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// All benchmarks will be executed, even if a error occur in the package before, the next package's benchmarks get executed
+// - packagesToBench: List of directory path that contains '*_test.go' files to run the benchmarks of
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file, it will contain the output in the format read by benchstat
+// - options: The BenchmarkOptions that define the flags and environment of the benchmarks
+// It returns the results of all benchmarks and any error that may occur or an empty list
+func RunBenchmarks(packagesToBench []string, logDir, logFileName string, options BenchmarkOptions) ([]BenchmarkResult, []error) {
+	results := []BenchmarkResult{}
+	benchErrors := []error{}
+
+	if err := EnsureDirectoryExists(logDir); err != nil {
+		return results, append(benchErrors, err)
+	}
+
+	logPath := filepath.Join(logDir, logFileName)
+	logFile, errOpen := os.Create(logPath)
+	if errOpen != nil {
+		return results, append(benchErrors, errOpen)
+	}
+	defer logFile.Close()
+
+	for _, packToBench := range packagesToBench {
+		fmt.Println(fmt.Sprintf("Benchmark package '%s', logging to '%s'", packToBench, logPath))
+		args := getBenchmarkArgs(options)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToBench, "go", strings.Join(args, " "), logPath))
+
+		var output bytes.Buffer
+		cmd := exec.Command("go", args...)
+		cmd.Dir = packToBench
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
+		cmd.Stdout = io.MultiWriter(logFile, &output)
+		cmd.Stderr = logFile
+		errBench := cmd.Run()
+
+		packageResults, errParse := parseBenchmarkOutput(&output)
+		if errParse != nil && errBench == nil {
+			errBench = errParse
+		}
+		for i := range packageResults {
+			if packageResults[i].Package == "" {
+				packageResults[i].Package = packToBench
+			}
+		}
+		results = append(results, packageResults...)
+		if errBench != nil {
+			errBench = fmt.Errorf("Error: Benchmarks of package '%s' failed. %w", packToBench, errBench)
+			fmt.Fprintln(os.Stderr, errBench)
+			benchErrors = append(benchErrors, errBench)
+		}
+	}
+
+	return results, benchErrors
+}
+
+// ReadBenchmarkLog - Read a benchmark log, like the one written by RunBenchmarks
+// - logPath: The path to the log to read
+// It returns the results of all benchmarks in the log and nil in case no error occur
+// In case of error the error and an empty list is returned
+func ReadBenchmarkLog(logPath string) ([]BenchmarkResult, error) {
+	logFile, errOpen := os.Open(logPath)
+	if errOpen != nil {
+		return []BenchmarkResult{}, errOpen
+	}
+	defer logFile.Close()
+
+	return parseBenchmarkOutput(logFile)
+}
+
+// WriteBenchmarkBaseline - Write the benchmark results as JSON baseline file for CompareBenchmarks
+// - results: The results of RunBenchmarks
+// - baselineFile: The path of the baseline file to write
+// It returns any error that may occur or nil
+func WriteBenchmarkBaseline(results []BenchmarkResult, baselineFile string) error {
+	content, errJson := json.MarshalIndent(results, "", "  ")
+	if errJson != nil {
+		return errJson
+	}
+
+	return os.WriteFile(baselineFile, content, 0644)
+}
+
+// CompareBenchmarks - Compare the benchmark results with the baseline file, similar to benchstat
+// The medians of the 'ns/op', 'B/op' and 'allocs/op' samples are compared, the significance of the difference is
+// evaluated with the Mann-Whitney U test. Benchmarks not part of the baseline are not compared
+// - results: The results of RunBenchmarks
+// - baselineFile: The path of the baseline file written by WriteBenchmarkBaseline
+// - threshold: The limits to check
+// It returns the comparison of all metrics and nil in case no metric regressed
+// If a metric regressed, a *BenchmarkRegression error is returned as well. In case of any other error the error and an empty list is returned
+func CompareBenchmarks(results []BenchmarkResult, baselineFile string, threshold BenchmarkThreshold) ([]BenchmarkComparison, error) {
+	content, errRead := os.ReadFile(baselineFile)
+	if errRead != nil {
+		return []BenchmarkComparison{}, errRead
+	}
+	baselineResults := []BenchmarkResult{}
+	if err := json.Unmarshal(content, &baselineResults); err != nil {
+		return []BenchmarkComparison{}, err
+	}
+	baseline := map[string]BenchmarkResult{}
+	for _, result := range baselineResults {
+		baseline[result.Package+" "+result.Name] = result
+	}
+
+	alpha := threshold.Alpha
+	if alpha <= 0 {
+		alpha = defaultBenchmarkAlpha
+	}
+
+	report := []BenchmarkComparison{}
+	regressions := []BenchmarkComparison{}
+	for _, result := range results {
+		baselineResult, found := baseline[result.Package+" "+result.Name]
+		if !found {
+			fmt.Println(fmt.Sprintf("Benchmark '%s' of '%s' is not part of the baseline", result.Name, result.Package))
+			continue
+		}
+
+		for _, unit := range []string{"ns/op", "B/op", "allocs/op"} {
+			values, baselineValues := getBenchmarkValues(result, unit), getBenchmarkValues(baselineResult, unit)
+			comparison := BenchmarkComparison{Package: result.Package, Name: result.Name, Unit: unit, Median: getMedian(values), BaselineMedian: getMedian(baselineValues)}
+			if comparison.BaselineMedian != 0 {
+				comparison.DeltaPercent = (comparison.Median - comparison.BaselineMedian) * 100 / comparison.BaselineMedian
+			} else if comparison.Median > 0 {
+				comparison.DeltaPercent = math.Inf(1)
+			}
+			comparison.PValue = getMannWhitneyUPValue(values, baselineValues)
+			comparison.Significant = comparison.PValue < alpha
+			comparison.Regression = comparison.Significant && comparison.DeltaPercent > threshold.MaxRegressionPercent
+			fmt.Println(fmt.Sprintf("Benchmark '%s' %s: %.2f (baseline %.2f, %+.2f%%, p=%.3f)", comparison.Name, comparison.Unit, comparison.Median, comparison.BaselineMedian, comparison.DeltaPercent, comparison.PValue))
+
+			if comparison.Regression {
+				regressions = append(regressions, comparison)
+			}
+			report = append(report, comparison)
+		}
+	}
+
+	if len(regressions) > 0 {
+		errRegression := NewBenchmarkRegression(regressions)
+		fmt.Fprintln(os.Stderr, errRegression)
+		return report, errRegression
+	}
+
+	return report, nil
+}
+
+// getBenchmarkArgs - Get the arguments for the 'go' command to run the benchmarks defined by the options
+func getBenchmarkArgs(options BenchmarkOptions) []string {
+	bench := options.Bench
+	if bench == "" {
+		bench = "."
+	}
+	count := options.Count
+	if count <= 0 {
+		count = defaultBenchmarkCount
+	}
+
+	args := []string{"test", "-run", "^$", "-bench", bench, "-benchmem", fmt.Sprintf("-count=%d", count)}
+	if options.BenchTime != "" {
+		args = append(args, fmt.Sprintf("-benchtime=%s", options.BenchTime))
+	}
+	if len(options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(options.Tags, ","))
+	}
+
+	return args
+}
+
+// parseBenchmarkOutput - Parse the output of 'go test -bench', lines that are no benchmark results are ignored
+// - reader: The output to parse
+// It returns the results in the order the benchmarks appear in the output and nil in case no error occur
+func parseBenchmarkOutput(reader io.Reader) ([]BenchmarkResult, error) {
+	results := []BenchmarkResult{}
+	indexes := map[string]int{}
+	currentPackage := ""
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			currentPackage = strings.TrimSpace(strings.TrimPrefix(line, "pkg: "))
+			continue
+		}
+
+		name, sample, ok := parseBenchmarkLine(line)
+		if !ok {
+			continue
+		}
+		key := currentPackage + " " + name
+		index, found := indexes[key]
+		if !found {
+			index = len(results)
+			indexes[key] = index
+			results = append(results, BenchmarkResult{Package: currentPackage, Name: name, Samples: []BenchmarkSample{}})
+		}
+		results[index].Samples = append(results[index].Samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return []BenchmarkResult{}, err
+	}
+
+	return results, nil
+}
+
+// parseBenchmarkLine - Parse a result line like 'BenchmarkAdd-8   1000000   1043 ns/op   128 B/op   2 allocs/op'
+// It returns the name of the benchmark, the sample and true if the line is a benchmark result
+func parseBenchmarkLine(line string) (string, BenchmarkSample, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return "", BenchmarkSample{}, false
+	}
+	iterations, errIterations := strconv.ParseInt(fields[1], 10, 64)
+	if errIterations != nil {
+		return "", BenchmarkSample{}, false
+	}
+
+	sample := BenchmarkSample{Iterations: iterations}
+	for i := 2; i < len(fields); i += 2 {
+		value, errValue := strconv.ParseFloat(fields[i], 64)
+		if errValue != nil {
+			return "", BenchmarkSample{}, false
+		}
+		switch fields[i+1] {
+		case "ns/op":
+			sample.NsPerOp = value
+		case "B/op":
+			sample.BytesPerOp = value
+		case "allocs/op":
+			sample.AllocsPerOp = value
+		}
+	}
+
+	return fields[0], sample, true
+}
+
+// getBenchmarkValues - Get the values of the given unit of all samples of the benchmark
+func getBenchmarkValues(result BenchmarkResult, unit string) []float64 {
+	values := []float64{}
+	for _, sample := range result.Samples {
+		switch unit {
+		case "ns/op":
+			values = append(values, sample.NsPerOp)
+		case "B/op":
+			values = append(values, sample.BytesPerOp)
+		case "allocs/op":
+			values = append(values, sample.AllocsPerOp)
+		}
+	}
+
+	return values
+}
+
+// getMedian - Get the median of the values, '0' for an empty list
+func getMedian(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
+
+// getMannWhitneyUPValue - Get the p-value of the two sided Mann-Whitney U test of the samples
+// The exact distribution of U is used for small samples without ties, the normal approximation with tie correction otherwise
+// It returns '1' if any of the samples is empty or all values are equal
+func getMannWhitneyUPValue(first, second []float64) float64 {
+	n1, n2 := len(first), len(second)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type rankedValue struct {
+		value float64
+		first bool
+	}
+	values := []rankedValue{}
+	for _, value := range first {
+		values = append(values, rankedValue{value, true})
+	}
+	for _, value := range second {
+		values = append(values, rankedValue{value, false})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].value < values[j].value })
+
+	rankSum, tieSum := 0.0, 0.0
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].value == values[i].value {
+			j++
+		}
+		averageRank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].first {
+				rankSum += averageRank
+			}
+		}
+		ties := float64(j - i)
+		tieSum += ties*ties*ties - ties
+		i = j
+	}
+
+	u := rankSum - float64(n1*(n1+1))/2
+	u = math.Min(u, float64(n1*n2)-u)
+	n := float64(n1 + n2)
+
+	if tieSum == 0 && n1+n2 <= 50 {
+		return math.Min(1, 2*getMannWhitneyUExactCdf(n1, n2, u))
+	}
+
+	sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - tieSum/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (float64(n1*n2)/2 - u - 0.5) / sigma
+	if z < 0 {
+		return 1
+	}
+
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// getMannWhitneyUExactCdf - Get the probability that U is less or equal u for samples of size n1 and n2 without ties
+// The number of orderings with a given U is counted with the recurrence of Mann and Whitney
+func getMannWhitneyUExactCdf(n1, n2 int, u float64) float64 {
+	counts := make([][][]float64, n1+1)
+	for i := 0; i <= n1; i++ {
+		counts[i] = make([][]float64, n2+1)
+		for j := 0; j <= n2; j++ {
+			current := make([]float64, i*j+1)
+			if i == 0 || j == 0 {
+				current[0] = 1
+			} else {
+				for k := range current {
+					if k >= j && k-j < len(counts[i-1][j]) {
+						current[k] += counts[i-1][j][k-j]
+					}
+					if k < len(counts[i][j-1]) {
+						current[k] += counts[i][j-1][k]
+					}
+				}
+			}
+			counts[i][j] = current
+		}
+	}
+
+	total, below := 0.0, 0.0
+	for k, count := range counts[n1][n2] {
+		total += count
+		if float64(k) <= u {
+			below += count
+		}
+	}
+
+	return below / total
+}
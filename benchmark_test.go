@@ -0,0 +1,167 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBenchmarks(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	packToBench := filepath.Join(".", "testdata", "testBenchProject")
+	results, errBench := RunBenchmarks([]string{packToBench}, baseDir, "Bench.log", BenchmarkOptions{Count: 2, BenchTime: "100x"})
+	if len(errBench) != 0 {
+		t.Fatalf("Got error '%s' but expected none", errBench[0].Error())
+	}
+	if len(results) != 1 || results[0].Package != "example.com/example-bench-project" || !strings.HasPrefix(results[0].Name, "BenchmarkSum") {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+	if len(results[0].Samples) != 2 || results[0].Samples[0].Iterations != 100 || results[0].Samples[0].NsPerOp <= 0 {
+		t.Errorf("Got the unexpected samples '%v'", results[0].Samples)
+	}
+
+	logResults, errRead := ReadBenchmarkLog(filepath.Join(baseDir, "Bench.log"))
+	if errRead != nil {
+		t.Errorf("Got error '%s' but expected none", errRead.Error())
+	}
+	if len(logResults) != 1 || len(logResults[0].Samples) != 2 {
+		t.Errorf("Got the unexpected results '%v'", logResults)
+	}
+
+	_, errBench = RunBenchmarks([]string{filepath.Join(".", "testdata", "testFailingProject")}, baseDir, "Bench.log", BenchmarkOptions{Bench: "NotExisting", Count: 1})
+	if len(errBench) != 0 {
+		t.Errorf("Got error '%s' but expected none", errBench[0].Error())
+	}
+
+	_, errBench = RunBenchmarks([]string{filepath.Join(".", "testdata", "not-existing-dir")}, baseDir, "Bench.log", BenchmarkOptions{Count: 1})
+	if len(errBench) != 1 {
+		t.Errorf("Expected '1' error, but got '%d'", len(errBench))
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	baselineFile := filepath.Join(baseDir, "bench.json")
+	baseline := []BenchmarkResult{{Package: "example.com/pack", Name: "BenchmarkAdd-8", Samples: getBenchmarkSamples(100, 101, 99, 100, 102, 98)}}
+	if err := WriteBenchmarkBaseline(baseline, baselineFile); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	comparisons, err := CompareBenchmarks(baseline, baselineFile, BenchmarkThreshold{MaxRegressionPercent: 5})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(comparisons) != 3 || comparisons[0].Unit != "ns/op" || comparisons[0].DeltaPercent != 0 || comparisons[0].Significant {
+		t.Errorf("Got the unexpected comparisons '%v'", comparisons)
+	}
+
+	slower := []BenchmarkResult{{Package: "example.com/pack", Name: "BenchmarkAdd-8", Samples: getBenchmarkSamples(120, 121, 119, 120, 122, 118)}}
+	comparisons, err = CompareBenchmarks(slower, baselineFile, BenchmarkThreshold{MaxRegressionPercent: 5})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if len(comparisons) != 3 || comparisons[0].DeltaPercent != 20 || !comparisons[0].Regression || comparisons[1].Regression {
+		t.Errorf("Got the unexpected comparisons '%v'", comparisons)
+	}
+
+	switch errType := err.(type) {
+	case *BenchmarkRegression:
+		if len(errType.Regressions()) != 1 || !strings.Contains(err.Error(), "BenchmarkAdd-8 ns/op") {
+			t.Errorf("The error '%s' does not name the regressed benchmark", err.Error())
+		}
+	default:
+		t.Errorf("Got error '%s' type, but expected '*BenchmarkRegression'", err.Error())
+	}
+
+	_, err = CompareBenchmarks(slower, baselineFile, BenchmarkThreshold{MaxRegressionPercent: 25})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	unknown := []BenchmarkResult{{Package: "example.com/pack", Name: "BenchmarkSub-8", Samples: getBenchmarkSamples(1)}}
+	comparisons, err = CompareBenchmarks(unknown, baselineFile, BenchmarkThreshold{})
+	if err != nil || len(comparisons) != 0 {
+		t.Errorf("Got the unexpected comparisons '%v'", comparisons)
+	}
+
+	_, err = CompareBenchmarks(slower, filepath.Join(baseDir, "not-existing.json"), BenchmarkThreshold{})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestReadBenchmarkLog(t *testing.T) {
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	logPath := filepath.Join(baseDir, "Bench.log")
+	log := "goos: linux\ngoarch: amd64\npkg: example.com/pack\nBenchmarkAdd-8   \t 1000000\t      1043 ns/op\t     128 B/op\t       2 allocs/op\n" +
+		"BenchmarkAdd-8   \t 1000000\t      1050 ns/op\t     128 B/op\t       2 allocs/op\nBenchmarkLog\nPASS\nok  \texample.com/pack\t2.1s\n"
+	if err := os.WriteFile(logPath, []byte(log), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	results, err := ReadBenchmarkLog(logPath)
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(results) != 1 || results[0].Package != "example.com/pack" || results[0].Name != "BenchmarkAdd-8" || len(results[0].Samples) != 2 {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+	if results[0].Samples[1] != (BenchmarkSample{Iterations: 1000000, NsPerOp: 1050, BytesPerOp: 128, AllocsPerOp: 2}) {
+		t.Errorf("Got the unexpected sample '%v'", results[0].Samples[1])
+	}
+
+	_, err = ReadBenchmarkLog(filepath.Join(baseDir, "not-existing.log"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetMannWhitneyUPValue(t *testing.T) {
+	// Reference values of R 'wilcox.test(x, y)$p.value'
+	p := getMannWhitneyUPValue([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+	if math.Abs(p-0.007937) > 0.00001 {
+		t.Errorf("Got the p-value '%f', but expected '0.007937'", p)
+	}
+
+	p = getMannWhitneyUPValue([]float64{1, 3, 5, 7}, []float64{2, 4, 6, 8})
+	if math.Abs(p-0.6857) > 0.0001 {
+		t.Errorf("Got the p-value '%f', but expected '0.6857'", p)
+	}
+
+	p = getMannWhitneyUPValue([]float64{2, 2, 2}, []float64{2, 2, 2})
+	if p != 1 {
+		t.Errorf("Got the p-value '%f', but expected '1'", p)
+	}
+
+	p = getMannWhitneyUPValue([]float64{}, []float64{1})
+	if p != 1 {
+		t.Errorf("Got the p-value '%f', but expected '1'", p)
+	}
+}
+
+func getBenchmarkSamples(nsPerOp ...float64) []BenchmarkSample {
+	samples := []BenchmarkSample{}
+	for _, value := range nsPerOp {
+		samples = append(samples, BenchmarkSample{Iterations: 1000, NsPerOp: value, BytesPerOp: 16, AllocsPerOp: 1})
+	}
+
+	return samples
+}
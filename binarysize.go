@@ -0,0 +1,195 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BinarySizeBudget - The limits checked by CheckBinarySizes. A value of '0' disables the check
+type BinarySizeBudget struct {
+	// The maximal size of any binary in bytes
+	MaxBytes int64
+	// The maximal growth of any binary compared to the baseline in percent
+	MaxGrowthPercent float64
+}
+
+// BinarySize - The size of one binary compared to the baseline
+type BinarySize struct {
+	Name          string
+	Size          int64
+	BaselineSize  int64
+	GrowthPercent float64
+}
+
+type BinarySizeBudgetExceeded struct {
+	err      string
+	binaries []BinarySize
+}
+
+func (e *BinarySizeBudgetExceeded) Error() string { // Implement the Error Interface for the BinarySizeBudgetExceeded struct
+	return fmt.Sprintf("Error: %s", e.err)
+}
+
+// ExceedingBinaries - Get the binaries that exceed the budget
+func (e *BinarySizeBudgetExceeded) ExceedingBinaries() []BinarySize {
+	return e.binaries
+}
+
+// NewBinarySizeBudgetExceeded - Get a new BinarySizeBudgetExceeded struct
+func NewBinarySizeBudgetExceeded(binaries []BinarySize) *BinarySizeBudgetExceeded {
+	names := []string{}
+	for _, binary := range binaries {
+		names = append(names, binary.Name)
+	}
+	return &BinarySizeBudgetExceeded{fmt.Sprintf("The binaries \"%s\" exceed the size budget", strings.Join(names, "\", \"")), binaries}
+}
+
+// GetBinarySizes - Get the size of all executables in binDir and its sub directories, like the '<os>_<arch>' directories of BuildFoldersForTargets
+// Executables are files with the '.exe' suffix, with an executable permission bit or starting with an ELF, Mach-O or PE header,
+// so binaries cross compiled for other platforms are found as well
+// - binDir: The output directory of the build
+// It returns a map of executable paths, relative to binDir with '/' as separator, to sizes in bytes and nil in case no error occur
+// In case of error the error and an empty map is returned
+func GetBinarySizes(binDir string) (map[string]int64, error) {
+	sizes := map[string]int64{}
+	errWalk := filepath.Walk(binDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		executable := filepath.Ext(path) == ".exe" || info.Mode().Perm()&0111 != 0
+		if !executable {
+			var errHeader error
+			executable, errHeader = hasExecutableHeader(path)
+			if errHeader != nil {
+				return errHeader
+			}
+		}
+		if !executable {
+			return nil
+		}
+
+		relPath, errRel := filepath.Rel(binDir, path)
+		if errRel != nil {
+			return errRel
+		}
+		sizes[filepath.ToSlash(relPath)] = info.Size()
+
+		return nil
+	})
+	if errWalk != nil {
+		return map[string]int64{}, errWalk
+	}
+
+	return sizes, nil
+}
+
+// hasExecutableHeader - Tell if the file starts with the header of an ELF, Mach-O or PE executable
+func hasExecutableHeader(path string) (bool, error) {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return false, errOpen
+	}
+	defer file.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false, nil
+	}
+
+	switch {
+	case bytes.Equal(header, []byte{0x7f, 'E', 'L', 'F'}):
+		return true, nil
+	case bytes.Equal(header, []byte{0xfe, 0xed, 0xfa, 0xce}), bytes.Equal(header, []byte{0xfe, 0xed, 0xfa, 0xcf}),
+		bytes.Equal(header, []byte{0xce, 0xfa, 0xed, 0xfe}), bytes.Equal(header, []byte{0xcf, 0xfa, 0xed, 0xfe}):
+		return true, nil
+	default:
+		return header[0] == 'M' && header[1] == 'Z', nil
+	}
+}
+
+// WriteBinarySizeBaseline - Write the size of all executables found by GetBinarySizes in binDir as JSON baseline file for CheckBinarySizes
+// - binDir: The output directory of the build
+// - baselineFile: The path of the baseline file to write
+// It returns any error that may occur or nil
+func WriteBinarySizeBaseline(binDir, baselineFile string) error {
+	sizes, errSizes := GetBinarySizes(binDir)
+	if errSizes != nil {
+		return errSizes
+	}
+
+	content, errJson := json.MarshalIndent(sizes, "", "  ")
+	if errJson != nil {
+		return errJson
+	}
+
+	return os.WriteFile(baselineFile, content, 0644)
+}
+
+// CheckBinarySizes - Compare the size of all executables found by GetBinarySizes in binDir with the baseline file and the budget
+// Binaries not part of the baseline, or all binaries if the baseline file does not exist, are only checked against budget.MaxBytes
+// - binDir: The output directory of the build
+// - baselineFile: The path of the baseline file written by WriteBinarySizeBaseline
+// - budget: The limits to check
+// It returns the sizes of all binaries sorted by name and nil in case all binaries are within the budget
+// If a binary exceeds the budget, a *BinarySizeBudgetExceeded error is returned as well. In case of any other error the error and an empty list is returned
+func CheckBinarySizes(binDir, baselineFile string, budget BinarySizeBudget) ([]BinarySize, error) {
+	sizes, errSizes := GetBinarySizes(binDir)
+	if errSizes != nil {
+		return []BinarySize{}, errSizes
+	}
+
+	baseline := map[string]int64{}
+	if PathExists(baselineFile) {
+		content, errRead := os.ReadFile(baselineFile)
+		if errRead != nil {
+			return []BinarySize{}, errRead
+		}
+		if err := json.Unmarshal(content, &baseline); err != nil {
+			return []BinarySize{}, err
+		}
+	}
+
+	names := []string{}
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := []BinarySize{}
+	exceeding := []BinarySize{}
+	for _, name := range names {
+		binary := BinarySize{Name: name, Size: sizes[name], BaselineSize: baseline[name]}
+		if binary.BaselineSize > 0 {
+			binary.GrowthPercent = float64(binary.Size-binary.BaselineSize) * 100 / float64(binary.BaselineSize)
+		}
+		fmt.Println(fmt.Sprintf("Binary '%s': %d bytes (baseline %d bytes, %+.2f%%)", binary.Name, binary.Size, binary.BaselineSize, binary.GrowthPercent))
+
+		if (budget.MaxBytes > 0 && binary.Size > budget.MaxBytes) || (budget.MaxGrowthPercent > 0 && binary.GrowthPercent > budget.MaxGrowthPercent) {
+			exceeding = append(exceeding, binary)
+		}
+		report = append(report, binary)
+	}
+
+	if len(exceeding) > 0 {
+		errBudget := NewBinarySizeBudgetExceeded(exceeding)
+		fmt.Fprintln(os.Stderr, errBudget)
+		return report, errBudget
+	}
+
+	return report, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckBinarySizes(t *testing.T) {
+	binDir := filepath.Join(baseDir, "bin")
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := EnsureDirectoryExists(binDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "tool.exe"), make([]byte, 1000), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "notes.txt"), make([]byte, 5000), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	baselineFile := filepath.Join(baseDir, "sizes.json")
+
+	sizes, err := CheckBinarySizes(binDir, baselineFile, BinarySizeBudget{MaxBytes: 2000, MaxGrowthPercent: 10})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(sizes) != 1 || sizes[0].Name != "tool.exe" || sizes[0].Size != 1000 {
+		t.Errorf("Got the unexpected sizes '%v'", sizes)
+	}
+
+	if err := WriteBinarySizeBaseline(binDir, baselineFile); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "tool.exe"), make([]byte, 1200), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	sizes, err = CheckBinarySizes(binDir, baselineFile, BinarySizeBudget{MaxBytes: 2000, MaxGrowthPercent: 10})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if len(sizes) != 1 || sizes[0].GrowthPercent != 20 {
+		t.Errorf("Got the unexpected sizes '%v'", sizes)
+	}
+
+	switch errType := err.(type) {
+	case *BinarySizeBudgetExceeded:
+		if len(errType.ExceedingBinaries()) != 1 || !strings.Contains(err.Error(), "tool.exe") {
+			t.Errorf("The error '%s' does not name the exceeding binary", err.Error())
+		}
+	default:
+		t.Errorf("Got error '%s' type, but expected '*BinarySizeBudgetExceeded'", err.Error())
+	}
+
+	_, err = CheckBinarySizes(binDir, baselineFile, BinarySizeBudget{MaxBytes: 1100})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	_, err = CheckBinarySizes(binDir, baselineFile, BinarySizeBudget{MaxBytes: 2000, MaxGrowthPercent: 25})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	_, err = CheckBinarySizes(filepath.Join(baseDir, "not-existing-dir"), baselineFile, BinarySizeBudget{})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestWriteBinarySizeBaseline(t *testing.T) {
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "tool.exe"), make([]byte, 10), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	baselineFile := filepath.Join(baseDir, "sizes.json")
+
+	if err := WriteBinarySizeBaseline(baseDir, baselineFile); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	content, _ := os.ReadFile(baselineFile)
+	baseline := map[string]int64{}
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if fmt.Sprint(baseline) != "map[tool.exe:10]" {
+		t.Errorf("Got the unexpected baseline '%v'", baseline)
+	}
+
+	if err := WriteBinarySizeBaseline(filepath.Join(baseDir, "not-existing-dir"), baselineFile); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetBinarySizesForTargets(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	binDir := filepath.Join(baseDir, "bin")
+	files := map[string][]byte{
+		"linux_arm64/tool":      append([]byte{0x7f, 'E', 'L', 'F'}, make([]byte, 6)...),
+		"darwin_arm64/tool":     append([]byte{0xcf, 0xfa, 0xed, 0xfe}, make([]byte, 16)...),
+		"windows_amd64/tool":    append([]byte{'M', 'Z'}, make([]byte, 28)...),
+		"windows_amd64/tool.sh": []byte("echo"),
+		"README.md":             []byte("# Tools"),
+	}
+	for name, content := range files {
+		path := filepath.Join(binDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("Got error '%s' but expected none", err.Error())
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Errorf("Got error '%s' but expected none", err.Error())
+		}
+	}
+
+	sizes, err := GetBinarySizes(binDir)
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if fmt.Sprint(sizes) != "map[darwin_arm64/tool:20 linux_arm64/tool:10 windows_amd64/tool:30]" {
+		t.Errorf("Got the unexpected sizes '%v'", sizes)
+	}
+
+	RemovePaths([]string{baseDir})
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goListPackage - The fields of a 'go list -json' package used to build the import graph
+type goListPackage struct {
+	Dir        string
+	ImportPath string
+	Standard   bool
+	Imports    []string
+}
+
+// GetChangedFiles - Get the files changed since the merge base of baseRef and HEAD, including not committed changes
+// and untracked files that are not ignored by git
+// - sourceDir: The directory this operation will run in, only changes within this directory are returned
+// - baseRef: The git reference to compare with, like 'origin/main'
+// It returns the sorted list of changed file paths, joined with sourceDir, and nil in case no error occur
+// In case of error the error and an empty list is returned
+func GetChangedFiles(sourceDir, baseRef string) ([]string, error) {
+	mergeBase, errBase := getGitMergeBase(sourceDir, baseRef)
+	if errBase != nil {
+		return []string{}, errBase
+	}
+
+	changedFiles, errDiff := getGitFileList(sourceDir, "diff", "--name-only", "--relative", mergeBase)
+	if errDiff != nil {
+		return []string{}, errDiff
+	}
+	untrackedFiles, errUntracked := getGitFileList(sourceDir, "ls-files", "--others", "--exclude-standard")
+	if errUntracked != nil {
+		return []string{}, errUntracked
+	}
+	changedFiles = append(changedFiles, untrackedFiles...)
+	sort.Strings(changedFiles)
+
+	return changedFiles, nil
+}
+
+// getGitFileList - Runs a git command that prints one file path, relative to sourceDir, per line
+// - sourceDir: The directory the command runs in
+// - args: The arguments of the git command
+// It returns the list of file paths, joined with sourceDir, and nil in case no error occur
+// In case of error the error and an empty list is returned
+func getGitFileList(sourceDir string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = sourceDir
+	cmd.Stderr = os.Stderr
+	output, errOutput := cmd.Output()
+	if errOutput != nil {
+		return []string{}, errOutput
+	}
+
+	files := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			files = append(files, filepath.Join(sourceDir, filepath.FromSlash(line)))
+		}
+	}
+
+	return files, nil
+}
+
+// FindPackagesToTestForChanges - Find the folders with tests like FindPackagesToTest, but only return the packages affected by changes since baseRef
+// The changed files of GetChangedFiles are mapped to the packages that contain them. Files in sub folders without go package,
+// like 'testdata', belong to the closest parent package. A changed 'go.mod', 'go.sum' or 'go.work' affects all packages below it.
+// Starting with these packages the reverse import graph of 'go list -deps -test -json' is walked to find every dependent test package
+// - sourceDir: The directory this function will start to search in recursively. Usually the repository root directory
+// - baseRef: The git reference to compare with, like 'origin/main'
+// It returns the list of directory paths, a subset of the FindPackagesToTest result, and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindPackagesToTestForChanges(sourceDir, baseRef string) ([]string, error) {
+	packagesToTest, errFind := FindPackagesToTest(sourceDir)
+	if errFind != nil {
+		return []string{}, errFind
+	}
+
+	changedFiles, errChanged := GetChangedFiles(sourceDir, baseRef)
+	if errChanged != nil {
+		return []string{}, errChanged
+	}
+	if len(changedFiles) == 0 {
+		fmt.Println(fmt.Sprintf("No files changed since '%s'", baseRef))
+		return []string{}, nil
+	}
+
+	packages, errList := listPackageGraph(sourceDir)
+	if errList != nil {
+		return []string{}, errList
+	}
+
+	affectedDirs := getAffectedPackageDirs(packages, changedFiles)
+	affectedPackages := []string{}
+	for _, packToTest := range packagesToTest {
+		absPath, errAbs := filepath.Abs(packToTest)
+		if errAbs != nil {
+			return []string{}, errAbs
+		}
+		if affectedDirs[absPath] {
+			affectedPackages = append(affectedPackages, packToTest)
+		}
+	}
+	fmt.Println(fmt.Sprintf("%d files changed since '%s', %d of %d packages to test are affected", len(changedFiles), baseRef, len(affectedPackages), len(packagesToTest)))
+
+	return affectedPackages, nil
+}
+
+// getGitMergeBase - Get the hash of the merge base of baseRef and HEAD
+// - workDir: The directory this operation will run in
+// - baseRef: The git reference to compare with, like 'origin/main'
+// It returns the hash and nil in case no error occur
+func getGitMergeBase(workDir, baseRef string) (string, error) {
+	cmd := exec.Command("git", "merge-base", baseRef, "HEAD")
+	cmd.Dir = workDir
+	cmd.Stderr = os.Stderr
+	mergeBase, errBase := cmd.Output()
+	if errBase != nil {
+		return "", fmt.Errorf("Error: Can not find the merge base of '%s' and HEAD. %w", baseRef, errBase)
+	}
+
+	return strings.TrimSpace(string(mergeBase)), nil
+}
+
+// listPackageGraph - Run 'go list -e -deps -test -json ./...' in sourceDir, if it is part of a module, and in all modules below it
+// It returns all listed packages, including the test variants, and nil in case no error occur
+func listPackageGraph(sourceDir string) ([]goListPackage, error) {
+	moduleDirs := []string{}
+	goMod, errEnv := getGoEnv(sourceDir, BuildOptions{}, "GOMOD")
+	if errEnv != nil {
+		return []goListPackage{}, errEnv
+	}
+	if len(goMod) == 1 && goMod[0] != "" && goMod[0] != os.DevNull {
+		moduleDirs = append(moduleDirs, sourceDir)
+	}
+	modules, errModules := FindPackagesToBuild(sourceDir)
+	if errModules != nil {
+		return []goListPackage{}, errModules
+	}
+	for _, module := range modules {
+		if filepath.Clean(module) != filepath.Clean(sourceDir) {
+			moduleDirs = append(moduleDirs, module)
+		}
+	}
+
+	packages := []goListPackage{}
+	for _, moduleDir := range moduleDirs {
+		fmt.Println(fmt.Sprintf("Run in %s: go list -e -deps -test -json ./...", moduleDir))
+		cmd := exec.Command("go", "list", "-e", "-deps", "-test", "-json", "./...")
+		cmd.Dir = moduleDir
+		cmd.Stderr = os.Stderr
+		output, errOutput := cmd.StdoutPipe()
+		if errOutput != nil {
+			return []goListPackage{}, errOutput
+		}
+		if err := cmd.Start(); err != nil {
+			return []goListPackage{}, err
+		}
+
+		decoder := json.NewDecoder(output)
+		for {
+			pack := goListPackage{}
+			errDecode := decoder.Decode(&pack)
+			if errDecode == io.EOF {
+				break
+			}
+			if errDecode != nil {
+				cmd.Wait()
+				return []goListPackage{}, fmt.Errorf("Error: Can not read the 'go list' output of '%s'. %w", moduleDir, errDecode)
+			}
+			if !pack.Standard && pack.Dir != "" {
+				packages = append(packages, pack)
+			}
+		}
+		if err := cmd.Wait(); err != nil {
+			return []goListPackage{}, fmt.Errorf("Error: 'go list' failed in '%s'. %w", moduleDir, err)
+		}
+	}
+
+	return packages, nil
+}
+
+// getAffectedPackageDirs - Walk the reverse import graph starting with the packages that contain a changed file
+// - packages: The packages of the import graph
+// - changedFiles: The changed file paths
+// It returns the absolute directories of all affected packages
+func getAffectedPackageDirs(packages []goListPackage, changedFiles []string) map[string]bool {
+	importers := map[string][]string{}
+	packageDirs := map[string]string{}
+	for _, pack := range packages {
+		packageDirs[pack.ImportPath] = filepath.Clean(pack.Dir)
+		for _, imported := range pack.Imports {
+			importers[imported] = append(importers[imported], pack.ImportPath)
+		}
+	}
+
+	changedDirs := map[string]bool{}
+	for _, changedFile := range changedFiles {
+		absPath, errAbs := filepath.Abs(changedFile)
+		if errAbs != nil {
+			continue
+		}
+		name := filepath.Base(absPath)
+		if name == "go.mod" || name == "go.sum" || name == "go.work" {
+			for _, dir := range packageDirs {
+				if isPathWithin(dir, filepath.Dir(absPath)) {
+					changedDirs[dir] = true
+				}
+			}
+			continue
+		}
+
+		owner := ""
+		for _, dir := range packageDirs {
+			if isPathWithin(filepath.Dir(absPath), dir) && len(dir) > len(owner) {
+				owner = dir
+			}
+		}
+		if owner != "" {
+			changedDirs[owner] = true
+		}
+	}
+
+	queue := []string{}
+	visited := map[string]bool{}
+	for importPath, dir := range packageDirs {
+		if changedDirs[dir] {
+			queue = append(queue, importPath)
+			visited[importPath] = true
+		}
+	}
+	affectedDirs := map[string]bool{}
+	for len(queue) > 0 {
+		importPath := queue[0]
+		queue = queue[1:]
+		affectedDirs[packageDirs[importPath]] = true
+		for _, importer := range importers[importPath] {
+			if !visited[importer] {
+				visited[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+
+	return affectedDirs
+}
+
+// isPathWithin - Tell if path is the same as dir or a path below dir
+func isPathWithin(path, dir string) bool {
+	relPath, errRel := filepath.Rel(dir, path)
+	return errRel == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
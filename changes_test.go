@@ -0,0 +1,152 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPackagesToTestForChanges(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	projectDir := filepath.Join(baseDir, "graph")
+	writeImportGraphProject(t, projectDir)
+
+	packages, err := FindPackagesToTestForChanges(projectDir, "HEAD")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(packages) != 0 {
+		t.Errorf("Got the unexpected packages '%v'", packages)
+	}
+
+	if err := os.WriteFile(filepath.Join(projectDir, "lib", "lib.go"), []byte("package lib\n\nfunc Value() int {\n\treturn 2\n}\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	packages, err = FindPackagesToTestForChanges(projectDir, "HEAD")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	expected := []string{filepath.Join(projectDir, "app"), filepath.Join(projectDir, "check"), filepath.Join(projectDir, "lib")}
+	if fmt.Sprint(packages) != fmt.Sprint(expected) {
+		t.Errorf("Got the packages '%v', but expected '%v'", packages, expected)
+	}
+
+	runGitCommand(t, projectDir, "checkout", "--", ".")
+	if err := os.WriteFile(filepath.Join(projectDir, "other", "testdata", "input.txt"), []byte("changed"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	packages, err = FindPackagesToTestForChanges(projectDir, "HEAD")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if fmt.Sprint(packages) != fmt.Sprint([]string{filepath.Join(projectDir, "other")}) {
+		t.Errorf("Got the unexpected packages '%v'", packages)
+	}
+
+	runGitCommand(t, projectDir, "checkout", "--", ".")
+	if err := os.WriteFile(filepath.Join(projectDir, "go.mod"), []byte("module example.com/graph\n\ngo 1.19\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	packages, err = FindPackagesToTestForChanges(projectDir, "HEAD")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(packages) != 4 {
+		t.Errorf("Got the unexpected packages '%v'", packages)
+	}
+
+	_, err = FindPackagesToTestForChanges(projectDir, "not-existing-ref")
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetChangedFiles(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	projectDir := filepath.Join(baseDir, "graph")
+	writeImportGraphProject(t, projectDir)
+
+	if err := os.WriteFile(filepath.Join(projectDir, "app", "app.go"), []byte("package app\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	runGitCommand(t, projectDir, "commit", "-q", "-a", "-m", "Change app")
+	if err := os.Remove(filepath.Join(projectDir, "lib", "lib_test.go")); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	if err := os.WriteFile(filepath.Join(projectDir, "app", "new.go"), []byte("package app\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, ".gitignore"), []byte("*.tmp\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "app", "ignored.tmp"), []byte("ignored"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	changedFiles, err := GetChangedFiles(projectDir, "HEAD~1")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	expected := []string{filepath.Join(projectDir, ".gitignore"), filepath.Join(projectDir, "app", "app.go"), filepath.Join(projectDir, "app", "new.go"), filepath.Join(projectDir, "lib", "lib_test.go")}
+	if fmt.Sprint(changedFiles) != fmt.Sprint(expected) {
+		t.Errorf("Got the changed files '%v', but expected '%v'", changedFiles, expected)
+	}
+
+	changedFiles, err = GetChangedFiles(filepath.Join(projectDir, "lib"), "HEAD~1")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if fmt.Sprint(changedFiles) != fmt.Sprint([]string{filepath.Join(projectDir, "lib", "lib_test.go")}) {
+		t.Errorf("Got the unexpected changed files '%v'", changedFiles)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+// writeImportGraphProject - Write a module into its own git repository, with 'app' importing 'lib' and the 'check_test' package importing 'app'
+func writeImportGraphProject(t *testing.T, projectDir string) {
+	files := map[string]string{
+		"go.mod":                   "module example.com/graph\n\ngo 1.18\n",
+		"lib/lib.go":               "package lib\n\nfunc Value() int {\n\treturn 1\n}\n",
+		"lib/lib_test.go":          "package lib\n\nimport \"testing\"\n\nfunc TestValue(t *testing.T) {\n\tValue()\n}\n",
+		"app/app.go":               "package app\n\nimport \"example.com/graph/lib\"\n\nfunc Run() int {\n\treturn lib.Value()\n}\n",
+		"app/app_test.go":          "package app\n\nimport \"testing\"\n\nfunc TestRun(t *testing.T) {\n\tRun()\n}\n",
+		"check/check_test.go":      "package check_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/graph/app\"\n)\n\nfunc TestCheck(t *testing.T) {\n\tapp.Run()\n}\n",
+		"other/other.go":           "package other\n",
+		"other/other_test.go":      "package other\n\nimport \"testing\"\n\nfunc TestOther(t *testing.T) {\n}\n",
+		"other/testdata/input.txt": "input",
+		"README.md":                "# Graph\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(projectDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Got error '%s' but expected none", err.Error())
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Got error '%s' but expected none", err.Error())
+		}
+	}
+
+	runGitCommand(t, projectDir, "init", "-q")
+	runGitCommand(t, projectDir, "add", "-A")
+	runGitCommand(t, projectDir, "commit", "-q", "-m", "Initial")
+}
+
+// runGitCommand - Run git with a fixed identity in the given directory
+func runGitCommand(t *testing.T, workDir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = workDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Running 'git %v' failed with '%s': %s", args, err.Error(), output)
+	}
+}
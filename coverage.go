@@ -0,0 +1,345 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CoverProfileBlock - One block of a go cover profile
+type CoverProfileBlock struct {
+	// The file name as import path, like 'example.com/module/pkg/file.go'
+	FileName      string
+	StartLine     int
+	StartCol      int
+	EndLine       int
+	EndCol        int
+	NumStatements int
+	Count         int
+}
+
+// CoverProfile - The content of a go cover profile, as written by 'go test -coverprofile'
+type CoverProfile struct {
+	// The cover mode, 'set', 'count' or 'atomic'
+	Mode   string
+	Blocks []CoverProfileBlock
+}
+
+// PackageCoverage - The statement coverage of a package
+type PackageCoverage struct {
+	Package           string
+	Statements        int
+	CoveredStatements int
+	Percent           float64
+}
+
+// CoverageReport - The total and per package statement coverage of a cover profile
+type CoverageReport struct {
+	// The path of the merged cover profile, may be empty
+	ProfilePath       string
+	Statements        int
+	CoveredStatements int
+	Percent           float64
+	Packages          []PackageCoverage
+}
+
+// CoverageThreshold - The minimal coverage checked by CheckCoverageThreshold. A value of '0' disables the check
+type CoverageThreshold struct {
+	// The minimal total coverage in percent
+	MinTotal float64
+	// The minimal coverage of any single package in percent
+	MinPackage float64
+}
+
+type CoverageBelowThreshold struct {
+	err      string
+	coverage []PackageCoverage
+}
+
+func (e *CoverageBelowThreshold) Error() string { // Implement the Error Interface for the CoverageBelowThreshold struct
+	return fmt.Sprintf("Error: %s", e.err)
+}
+
+// BelowThreshold - Get the coverage that is below the threshold. The total coverage is named 'total'
+func (e *CoverageBelowThreshold) BelowThreshold() []PackageCoverage {
+	return e.coverage
+}
+
+// NewCoverageBelowThreshold - Get a new CoverageBelowThreshold struct
+func NewCoverageBelowThreshold(coverage []PackageCoverage) *CoverageBelowThreshold {
+	details := []string{}
+	for _, item := range coverage {
+		details = append(details, fmt.Sprintf("%s: %.1f%%", item.Package, item.Percent))
+	}
+	return &CoverageBelowThreshold{fmt.Sprintf("The coverage is below the threshold for \"%s\"", strings.Join(details, "\", \"")), coverage}
+}
+
+// CoverTestFoldersWithProfile - Runs 'go test -v -coverprofile=<file>' on all given packages to test, creates a log file with the output and merges the profiles
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// Each package's profile is written next to the log file, named after the package path with the '.coverprofile' suffix
+// - packagesToCover: List of directory path that contains '*_test.go' files test coverage should be measured
+// - logDir: Path to the directory the log file and the profiles are crated
+// - logFileName: Name of the log file
+// - profileName: Name of the merged profile file
+// It returns the CoverageReport of the merged profile and nil in case no error occur
+// In case of error the error and an empty CoverageReport is returned
+func CoverTestFoldersWithProfile(packagesToCover []string, logDir, logFileName, profileName string) (CoverageReport, error) {
+	return CoverTestFoldersWithProfileAndOptions(packagesToCover, logDir, logFileName, profileName, TestOptions{})
+}
+
+// CoverTestFoldersWithProfileAndOptions - Runs 'go test -v -coverprofile=<file> <options>' on all given packages to test, creates a log file with the output and merges the profiles
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// Each package's profile is written next to the log file, named after the package path with the '.coverprofile' suffix
+// - packagesToCover: List of directory path that contains '*_test.go' files test coverage should be measured
+// - logDir: Path to the directory the log file and the profiles are crated
+// - logFileName: Name of the log file
+// - profileName: Name of the merged profile file
+// - options: The TestOptions that define the flags, environment, watchdog and context of the tests
+// It returns the CoverageReport of the merged profile and nil in case no error occur
+// In case of error the error and an empty CoverageReport is returned
+func CoverTestFoldersWithProfileAndOptions(packagesToCover []string, logDir, logFileName, profileName string, options TestOptions) (CoverageReport, error) {
+	if err := EnsureDirectoryExists(logDir); err != nil {
+		return CoverageReport{}, err
+	}
+	absLogDir, errAbs := filepath.Abs(logDir)
+	if errAbs != nil {
+		return CoverageReport{}, errAbs
+	}
+
+	logPath := filepath.Join(logDir, logFileName)
+	logFile, errOpen := os.Create(logPath)
+	if errOpen != nil {
+		return CoverageReport{}, errOpen
+	}
+	defer logFile.Close()
+
+	profiles := []CoverProfile{}
+	for _, packToTest := range packagesToCover {
+		profilePath := filepath.Join(absLogDir, strings.TrimSuffix(getPackageLogName(packToTest), ".log")+".coverprofile")
+
+		fmt.Println(fmt.Sprintf("Measure test coverage for package '%s', logging to '%s'", packToTest, logPath))
+		args := append(append(getCoverRaceTestArgs("-v", options), fmt.Sprintf("-coverprofile=%s", profilePath)), getTestOptionArgs(options)...)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToTest, "go", strings.Join(args, " "), logPath))
+		fmt.Fprintln(logFile, getRaceLogHeader(packToTest, hasRaceArg(args)))
+		cmd := exec.Command("go", args...)
+		cmd.Dir = packToTest
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
+		cmd.Stderr = logFile
+		cmd.Stdout = logFile
+		timedOut, errTest := runCommandWithWatchdog(options.Context, cmd, options.Watchdog)
+		if timedOut {
+			errTest = NewTestTimedOut(packToTest, options.Watchdog)
+		}
+		if errTest != nil {
+			errTest = fmt.Errorf("Error: Coverage measurement of package '%s' failed. %w", packToTest, errTest)
+			fmt.Fprintln(os.Stderr, errTest)
+			return CoverageReport{}, errTest
+		}
+
+		profile, errRead := ReadCoverProfile(profilePath)
+		if errRead != nil {
+			return CoverageReport{}, errRead
+		}
+		profiles = append(profiles, profile)
+	}
+
+	merged, errMerge := MergeCoverProfiles(profiles)
+	if errMerge != nil {
+		return CoverageReport{}, errMerge
+	}
+
+	mergedPath := filepath.Join(logDir, profileName)
+	if err := WriteCoverProfile(mergedPath, merged); err != nil {
+		return CoverageReport{}, err
+	}
+
+	report := GetCoverageReport(merged)
+	report.ProfilePath = mergedPath
+	fmt.Println(fmt.Sprintf("Total coverage: %.1f%% of statements, merged profile '%s'", report.Percent, mergedPath))
+
+	return report, nil
+}
+
+// CheckCoverageThreshold - Check the CoverageReport against the threshold
+// - report: The CoverageReport to check
+// - threshold: The minimal coverage
+// It returns a *CoverageBelowThreshold error if the total or any package coverage is below the threshold, otherwise nil
+func CheckCoverageThreshold(report CoverageReport, threshold CoverageThreshold) error {
+	below := []PackageCoverage{}
+	if threshold.MinTotal > 0 && report.Percent < threshold.MinTotal {
+		below = append(below, PackageCoverage{Package: "total", Statements: report.Statements, CoveredStatements: report.CoveredStatements, Percent: report.Percent})
+	}
+
+	if threshold.MinPackage > 0 {
+		for _, packageCoverage := range report.Packages {
+			if packageCoverage.Percent < threshold.MinPackage {
+				below = append(below, packageCoverage)
+			}
+		}
+	}
+
+	if len(below) > 0 {
+		errThreshold := NewCoverageBelowThreshold(below)
+		fmt.Fprintln(os.Stderr, errThreshold)
+		return errThreshold
+	}
+
+	return nil
+}
+
+// GetCoverageReport - Calculate the total and per package statement coverage of a CoverProfile
+// - profile: The CoverProfile to calculate the coverage for
+// It returns the CoverageReport, the packages are sorted by name
+func GetCoverageReport(profile CoverProfile) CoverageReport {
+	report := CoverageReport{Packages: []PackageCoverage{}}
+	packages := map[string]*PackageCoverage{}
+	names := []string{}
+
+	for _, block := range profile.Blocks {
+		packageName := path.Dir(block.FileName)
+		packageCoverage, found := packages[packageName]
+		if !found {
+			packageCoverage = &PackageCoverage{Package: packageName}
+			packages[packageName] = packageCoverage
+			names = append(names, packageName)
+		}
+
+		packageCoverage.Statements += block.NumStatements
+		report.Statements += block.NumStatements
+		if block.Count > 0 {
+			packageCoverage.CoveredStatements += block.NumStatements
+			report.CoveredStatements += block.NumStatements
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		packageCoverage := packages[name]
+		packageCoverage.Percent = getCoveragePercent(packageCoverage.CoveredStatements, packageCoverage.Statements)
+		report.Packages = append(report.Packages, *packageCoverage)
+	}
+	report.Percent = getCoveragePercent(report.CoveredStatements, report.Statements)
+
+	return report
+}
+
+// ReadCoverProfile - Read a go cover profile, as written by 'go test -coverprofile'
+// - profilePath: The path to the profile
+// It returns the CoverProfile and nil in case no error occur
+// In case of error the error and an empty CoverProfile is returned
+func ReadCoverProfile(profilePath string) (CoverProfile, error) {
+	byteContent, errRead := os.ReadFile(profilePath)
+	if errRead != nil {
+		return CoverProfile{}, errRead
+	}
+
+	profile := CoverProfile{Blocks: []CoverProfileBlock{}}
+	for i, line := range strings.Split(string(byteContent), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "mode: ") {
+			profile.Mode = strings.TrimPrefix(line, "mode: ")
+			continue
+		}
+
+		separator := strings.LastIndex(line, ":")
+		block := CoverProfileBlock{}
+		if separator > 0 {
+			block.FileName = line[:separator]
+			_, errScan := fmt.Sscanf(line[separator+1:], "%d.%d,%d.%d %d %d", &block.StartLine, &block.StartCol, &block.EndLine, &block.EndCol, &block.NumStatements, &block.Count)
+			if errScan == nil {
+				profile.Blocks = append(profile.Blocks, block)
+				continue
+			}
+		}
+
+		return CoverProfile{}, fmt.Errorf("Error: Line %d of the cover profile '%s' is not valid", i+1, profilePath)
+	}
+
+	return profile, nil
+}
+
+// WriteCoverProfile - Write a go cover profile, that can be used with 'go tool cover'
+// - profilePath: The path of the profile to write
+// - profile: The CoverProfile to write
+// It returns any error that may occur or nil
+func WriteCoverProfile(profilePath string, profile CoverProfile) error {
+	lines := []string{fmt.Sprintf("mode: %s", profile.Mode)}
+	for _, block := range profile.Blocks {
+		lines = append(lines, fmt.Sprintf("%s:%d.%d,%d.%d %d %d", block.FileName, block.StartLine, block.StartCol, block.EndLine, block.EndCol, block.NumStatements, block.Count))
+	}
+
+	return os.WriteFile(profilePath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// MergeCoverProfiles - Merge many cover profiles into a single one
+// Blocks found in more than one profile are summed up, or in 'set' mode marked as covered if any profile covers them
+// - profiles: The profiles to merge, all need the same mode
+// It returns the merged CoverProfile, its blocks sorted by file and position, and nil in case no error occur
+// In case of error the error and an empty CoverProfile is returned
+func MergeCoverProfiles(profiles []CoverProfile) (CoverProfile, error) {
+	merged := CoverProfile{Mode: "set", Blocks: []CoverProfileBlock{}}
+	blockIndex := map[string]int{}
+
+	for i, profile := range profiles {
+		if i == 0 {
+			merged.Mode = profile.Mode
+		} else if profile.Mode != merged.Mode {
+			return CoverProfile{}, fmt.Errorf("Error: Can not merge cover profiles with the modes '%s' and '%s'", merged.Mode, profile.Mode)
+		}
+
+		for _, block := range profile.Blocks {
+			key := fmt.Sprintf("%s:%d.%d,%d.%d", block.FileName, block.StartLine, block.StartCol, block.EndLine, block.EndCol)
+			index, found := blockIndex[key]
+			if !found {
+				blockIndex[key] = len(merged.Blocks)
+				merged.Blocks = append(merged.Blocks, block)
+				continue
+			}
+
+			if merged.Mode == "set" {
+				if block.Count > 0 {
+					merged.Blocks[index].Count = 1
+				}
+			} else {
+				merged.Blocks[index].Count += block.Count
+			}
+		}
+	}
+
+	sort.SliceStable(merged.Blocks, func(i, j int) bool {
+		first, second := merged.Blocks[i], merged.Blocks[j]
+		if first.FileName != second.FileName {
+			return first.FileName < second.FileName
+		}
+		if first.StartLine != second.StartLine {
+			return first.StartLine < second.StartLine
+		}
+		return first.StartCol < second.StartCol
+	})
+
+	return merged, nil
+}
+
+// getCoveragePercent - Get the coverage in percent, '100' if there are no statements
+func getCoveragePercent(covered, statements int) float64 {
+	if statements == 0 {
+		return 100
+	}
+
+	return float64(covered) * 100 / float64(statements)
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const firstTestProfile = `mode: set
+example.com/mod/a/a.go:3.20,5.2 1 1
+example.com/mod/a/a.go:7.20,9.2 1 0
+example.com/mod/b/b.go:3.20,5.2 2 0
+`
+
+const secondTestProfile = `mode: set
+example.com/mod/b/b.go:3.20,5.2 2 1
+example.com/mod/a/a.go:7.20,9.2 1 0
+`
+
+func TestCoverTestFoldersWithProfile(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToTest(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	report, err := CoverTestFoldersWithProfile(dirs, baseDir, "TestCover.log", "coverage.out")
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	if report.ProfilePath != filepath.Join(baseDir, "coverage.out") || !PathExists(report.ProfilePath) {
+		t.Errorf("The merged profile '%s' does not exist", report.ProfilePath)
+	}
+	if !PathExists(filepath.Join(baseDir, strings.TrimSuffix(getPackageLogName(dirs[0]), ".log")+".coverprofile")) {
+		t.Errorf("The package profile was not created")
+	}
+	if len(report.Packages) != 1 || report.Packages[0].Package != "example.com/example-project" {
+		t.Errorf("Got the unexpected package coverage '%v'", report.Packages)
+	}
+	if report.Percent <= 0 || report.Percent >= 100 {
+		t.Errorf("Expected a partial coverage, but got '%.1f'", report.Percent)
+	}
+
+	_, err = CoverTestFoldersWithProfile([]string{filepath.Join(".", "testdata", "no.go")}, baseDir, "TestCover.log", "coverage.out")
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestCoverTestFoldersWithProfileAndOptions(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	failingDir := filepath.Join(".", "testdata", "testFailingProject")
+	report, err := CoverTestFoldersWithProfileAndOptions([]string{failingDir}, baseDir, "TestCover.log", "coverage.out", TestOptions{Run: "TestSubPass", Count: 1})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if report.Percent != 100 {
+		t.Errorf("Expected a full coverage, but got '%.1f'", report.Percent)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestMergeCoverProfiles(t *testing.T) {
+	first, second := writeTestProfiles(t)
+
+	merged, err := MergeCoverProfiles([]CoverProfile{first, second})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if merged.Mode != "set" || len(merged.Blocks) != 3 {
+		t.Fatalf("Got the unexpected merged profile '%v'", merged)
+	}
+	if merged.Blocks[2].FileName != "example.com/mod/b/b.go" || merged.Blocks[2].Count != 1 {
+		t.Errorf("The block '%v' was not merged as expected", merged.Blocks[2])
+	}
+
+	second.Mode = "count"
+	_, err = MergeCoverProfiles([]CoverProfile{first, second})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	profilePath := filepath.Join(baseDir, "merged.out")
+	if err := WriteCoverProfile(profilePath, merged); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	reread, err := ReadCoverProfile(profilePath)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(reread.Blocks) != 3 || reread.Blocks[0] != merged.Blocks[0] {
+		t.Errorf("The profile '%v' was not written as expected", reread)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestCheckCoverageThreshold(t *testing.T) {
+	first, _ := writeTestProfiles(t)
+	report := GetCoverageReport(first)
+
+	if report.Statements != 4 || report.CoveredStatements != 1 || report.Percent != 25 {
+		t.Errorf("Got the unexpected total coverage '%v'", report)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Percent != 50 || report.Packages[1].Percent != 0 {
+		t.Errorf("Got the unexpected package coverage '%v'", report.Packages)
+	}
+
+	if err := CheckCoverageThreshold(report, CoverageThreshold{MinTotal: 20}); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	err := CheckCoverageThreshold(report, CoverageThreshold{MinTotal: 30, MinPackage: 40})
+	if err == nil {
+		t.Fatalf("Got no error, but expected one")
+	}
+
+	switch errType := err.(type) {
+	case *CoverageBelowThreshold:
+		below := errType.BelowThreshold()
+		if len(below) != 2 || below[0].Package != "total" || below[1].Package != "example.com/mod/b" {
+			t.Errorf("Got the unexpected coverage below threshold '%v'", below)
+		}
+	default:
+		t.Errorf("Got error '%s' type, but expected '*CoverageBelowThreshold'", err.Error())
+	}
+	if !strings.Contains(err.Error(), "example.com/mod/b: 0.0%") {
+		t.Errorf("The error '%s' does not name the package", err.Error())
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestReadCoverProfile(t *testing.T) {
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	invalidPath := filepath.Join(baseDir, "invalid.out")
+	if err := os.WriteFile(invalidPath, []byte("mode: set\nnot a block\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	_, err := ReadCoverProfile(invalidPath)
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	_, err = ReadCoverProfile(filepath.Join(baseDir, "not-existing-file"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func writeTestProfiles(t *testing.T) (CoverProfile, CoverProfile) {
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Fatalf("Got error '%s' but expected none", err.Error())
+	}
+
+	profiles := []CoverProfile{}
+	for i, content := range []string{firstTestProfile, secondTestProfile} {
+		profilePath := filepath.Join(baseDir, []string{"first.out", "second.out"}[i])
+		if err := os.WriteFile(profilePath, []byte(content), 0644); err != nil {
+			t.Fatalf("Got error '%s' but expected none", err.Error())
+		}
+		profile, err := ReadCoverProfile(profilePath)
+		if err != nil {
+			t.Fatalf("Got error '%s' but expected none", err.Error())
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles[0], profiles[1]
+}
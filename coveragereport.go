@@ -0,0 +1,254 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coberturaCoverage - The root element of a Cobertura XML report
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        string             `xml:"line-rate,attr"`
+	BranchRate      string             `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      string             `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+// coberturaPackage - A go package in a Cobertura XML report
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   string           `xml:"line-rate,attr"`
+	BranchRate string           `xml:"branch-rate,attr"`
+	Complexity string           `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+// coberturaClass - A go file in a Cobertura XML report
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	FileName   string          `xml:"filename,attr"`
+	LineRate   string          `xml:"line-rate,attr"`
+	BranchRate string          `xml:"branch-rate,attr"`
+	Complexity string          `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+// coberturaLine - The hits of a source line in a Cobertura XML report
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// WriteCoberturaReport - Write a go cover profile, like the merged profile of CoverTestFoldersWithProfile, as Cobertura XML report
+// The file names of the report are made relative to moduleDir by removing the module path, read from its 'go.mod' file
+// - profilePath: The path of the cover profile
+// - xmlPath: The path of the Cobertura XML file to write
+// - moduleDir: The root directory of the module, used as source directory of the report. If empty the file names stay import paths
+// It returns any error that may occur or nil
+func WriteCoberturaReport(profilePath, xmlPath, moduleDir string) error {
+	fmt.Println(fmt.Sprintf("Write the cobertura report of %s to %s", profilePath, xmlPath))
+	profile, errRead := ReadCoverProfile(profilePath)
+	if errRead != nil {
+		return errRead
+	}
+
+	modulePath := ""
+	sources := []string{}
+	if moduleDir != "" {
+		var errModule error
+		modulePath, errModule = ReadModulePath(moduleDir)
+		if errModule != nil {
+			return errModule
+		}
+		absModuleDir, errAbs := filepath.Abs(moduleDir)
+		if errAbs != nil {
+			return errAbs
+		}
+		sources = append(sources, absModuleDir)
+	}
+
+	report := getCoberturaCoverage(profile, modulePath)
+	report.Sources = sources
+
+	if err := EnsureDirectoryExists(filepath.Dir(xmlPath)); err != nil {
+		return err
+	}
+	content, errXml := xml.MarshalIndent(report, "", "  ")
+	if errXml != nil {
+		return errXml
+	}
+
+	return os.WriteFile(xmlPath, append([]byte(xml.Header), append(content, '\n')...), 0644)
+}
+
+// WriteCoverageHtml - Write a go cover profile, like the merged profile of CoverTestFoldersWithProfile, as browsable HTML report
+// It uses 'go tool cover -html', so all packages of the profile need to be found from workDir, e.g. by a 'go.mod' or 'go.work' file
+// - profilePath: The path of the cover profile
+// - htmlPath: The path of the HTML file to write
+// - workDir: The directory this operation will run in. Usually the module root directory
+// It returns any error that may occur or nil
+func WriteCoverageHtml(profilePath, htmlPath, workDir string) error {
+	absProfilePath, errProfile := filepath.Abs(profilePath)
+	if errProfile != nil {
+		return errProfile
+	}
+	absHtmlPath, errHtml := filepath.Abs(htmlPath)
+	if errHtml != nil {
+		return errHtml
+	}
+	if err := EnsureDirectoryExists(filepath.Dir(absHtmlPath)); err != nil {
+		return err
+	}
+
+	fmt.Println(fmt.Sprintf("Run in %s: %s %s %s %s %s %s", workDir, "go", "tool", "cover", fmt.Sprintf("-html=%s", absProfilePath), "-o", absHtmlPath))
+	cmd := exec.Command("go", "tool", "cover", fmt.Sprintf("-html=%s", absProfilePath), "-o", absHtmlPath)
+	cmd.Dir = workDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	errCover := cmd.Run()
+	if errCover != nil {
+		errCover = fmt.Errorf("Error: Creation of the HTML coverage report failed. %w", errCover)
+		fmt.Fprintln(os.Stderr, errCover)
+		return errCover
+	}
+
+	return nil
+}
+
+// ReadModulePath - Read the module path from the 'go.mod' file in the given directory
+// - moduleDir: The root directory of the module, that contains the 'go.mod' file
+// It returns the module path and nil in case no error occur
+// In case of error the error and an empty string is returned
+func ReadModulePath(moduleDir string) (string, error) {
+	goModPath := filepath.Join(moduleDir, "go.mod")
+	byteContent, errRead := os.ReadFile(goModPath)
+	if errRead != nil {
+		return "", errRead
+	}
+
+	for _, line := range strings.Split(string(byteContent), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") || strings.HasPrefix(line, "module\t") {
+			modulePath := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+			if unquoted, err := strconv.Unquote(modulePath); err == nil {
+				modulePath = unquoted
+			}
+			return modulePath, nil
+		}
+	}
+
+	return "", fmt.Errorf("Error: The file '%s' has no module directive", goModPath)
+}
+
+// getCoberturaCoverage - Convert a CoverProfile into the Cobertura XML structure, one class per file
+// - profile: The CoverProfile to convert
+// - modulePath: The module path removed from the file names, may be empty
+// It returns the root element of the report
+func getCoberturaCoverage(profile CoverProfile, modulePath string) coberturaCoverage {
+	fileLines := getCoverProfileLineHits(profile)
+
+	fileNames := []string{}
+	for fileName := range fileLines {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	report := coberturaCoverage{BranchRate: "0", Complexity: "0", Version: "gobuildhelpers", Timestamp: time.Now().UnixNano() / int64(time.Millisecond)}
+	packageIndex := map[string]int{}
+	packageLines := map[string][]int{}
+	for _, fileName := range fileNames {
+		packageName := path.Dir(fileName)
+		index, found := packageIndex[packageName]
+		if !found {
+			index = len(report.Packages)
+			packageIndex[packageName] = index
+			packageLines[packageName] = []int{0, 0}
+			report.Packages = append(report.Packages, coberturaPackage{Name: packageName, BranchRate: "0", Complexity: "0"})
+		}
+
+		relativeName := fileName
+		if modulePath != "" && strings.HasPrefix(fileName, modulePath+"/") {
+			relativeName = strings.TrimPrefix(fileName, modulePath+"/")
+		}
+		class := coberturaClass{Name: strings.TrimSuffix(path.Base(fileName), ".go"), FileName: relativeName, BranchRate: "0", Complexity: "0"}
+
+		numbers := []int{}
+		for number := range fileLines[fileName] {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+
+		covered := 0
+		for _, number := range numbers {
+			hits := fileLines[fileName][number]
+			class.Lines = append(class.Lines, coberturaLine{Number: number, Hits: hits})
+			if hits > 0 {
+				covered++
+			}
+		}
+		class.LineRate = getLineRate(covered, len(numbers))
+
+		report.Packages[index].Classes = append(report.Packages[index].Classes, class)
+		packageLines[packageName][0] += covered
+		packageLines[packageName][1] += len(numbers)
+		report.LinesCovered += covered
+		report.LinesValid += len(numbers)
+	}
+
+	for i, packageReport := range report.Packages {
+		report.Packages[i].LineRate = getLineRate(packageLines[packageReport.Name][0], packageLines[packageReport.Name][1])
+	}
+	report.LineRate = getLineRate(report.LinesCovered, report.LinesValid)
+
+	return report
+}
+
+// getCoverProfileLineHits - Get the hits of each line that is part of a block, by file name and line number
+// A line that is part of many blocks gets the highest count of these blocks
+func getCoverProfileLineHits(profile CoverProfile) map[string]map[int]int {
+	fileLines := map[string]map[int]int{}
+	for _, block := range profile.Blocks {
+		lines, found := fileLines[block.FileName]
+		if !found {
+			lines = map[int]int{}
+			fileLines[block.FileName] = lines
+		}
+		for line := block.StartLine; line <= block.EndLine; line++ {
+			if hits, known := lines[line]; !known || block.Count > hits {
+				lines[line] = block.Count
+			}
+		}
+	}
+
+	return fileLines
+}
+
+// getLineRate - Get the rate of covered lines as used by Cobertura XML, '1' if there are no lines
+func getLineRate(covered, valid int) string {
+	if valid == 0 {
+		return "1"
+	}
+
+	return strconv.FormatFloat(float64(covered)/float64(valid), 'f', 4, 64)
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCoberturaReport(t *testing.T) {
+	first, _ := writeTestProfiles(t)
+	profilePath := filepath.Join(baseDir, "first.out")
+	xmlPath := filepath.Join(baseDir, "cobertura.xml")
+
+	if err := os.WriteFile(filepath.Join(baseDir, "go.mod"), []byte("module example.com/mod\n\ngo 1.18\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	err := WriteCoberturaReport(profilePath, xmlPath, baseDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	content, errRead := os.ReadFile(xmlPath)
+	if errRead != nil {
+		t.Errorf("Got error '%s', but expected none", errRead.Error())
+	}
+	report := coberturaCoverage{}
+	if err := xml.Unmarshal(content, &report); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	if len(report.Packages) != 2 || report.Packages[0].Name != "example.com/mod/a" {
+		t.Fatalf("Got the unexpected packages '%v'", report.Packages)
+	}
+	class := report.Packages[0].Classes[0]
+	if class.FileName != "a/a.go" || class.Name != "a" || len(class.Lines) != 6 || class.LineRate != "0.5000" {
+		t.Errorf("Got the unexpected class '%v'", class)
+	}
+	if report.LinesValid != 9 || report.LinesCovered != 3 {
+		t.Errorf("Expected '3' of '9' lines covered, but got '%d' of '%d'", report.LinesCovered, report.LinesValid)
+	}
+	if len(first.Blocks) != 3 {
+		t.Errorf("Expected '3' blocks, but got '%d'", len(first.Blocks))
+	}
+
+	err = WriteCoberturaReport(profilePath, xmlPath, "")
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	content, _ = os.ReadFile(xmlPath)
+	if !strings.Contains(string(content), "filename=\"example.com/mod/a/a.go\"") {
+		t.Errorf("The report does not contain the file name as import path")
+	}
+
+	err = WriteCoberturaReport(profilePath, xmlPath, filepath.Join(baseDir, "not-existing-dir"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestWriteCoverageHtml(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToTest(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	report, err := CoverTestFoldersWithProfile(dirs, baseDir, "TestCover.log", "coverage.out")
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	htmlPath := filepath.Join(baseDir, "coverage.html")
+	err = WriteCoverageHtml(report.ProfilePath, htmlPath, dirs[0])
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if !PathExists(htmlPath) {
+		t.Errorf("The HTML report '%s' does not exist", htmlPath)
+	}
+
+	err = WriteCoberturaReport(report.ProfilePath, filepath.Join(baseDir, "cobertura.xml"), dirs[0])
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	err = WriteCoverageHtml(filepath.Join(baseDir, "not-existing-file"), htmlPath, dirs[0])
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestReadModulePath(t *testing.T) {
+	modulePath, err := ReadModulePath(".")
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if modulePath != "github.com/imker25/gobuildhelpers" {
+		t.Errorf("Expected the module path 'github.com/imker25/gobuildhelpers', but got '%s'", modulePath)
+	}
+
+	_, err = ReadModulePath(filepath.Join(".", "testdata", "no.go"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goListBuildPackage - The fields of a 'go list -json' package that define the files used by a build
+type goListBuildPackage struct {
+	Dir          string
+	ImportPath   string
+	Standard     bool
+	GoFiles      []string
+	CgoFiles     []string
+	CFiles       []string
+	CXXFiles     []string
+	MFiles       []string
+	HFiles       []string
+	FFiles       []string
+	SFiles       []string
+	SwigFiles    []string
+	SwigCXXFiles []string
+	SysoFiles    []string
+	EmbedFiles   []string
+	Module       *goListModule
+}
+
+// goListModule - The fields of a 'go list -json' module used to fingerprint a build
+type goListModule struct {
+	Path    string
+	Version string
+	Main    bool
+	GoMod   string
+	Replace *goListModule
+}
+
+// GetBuildFingerprint - Get a fingerprint of everything that influences the build of a package
+// The fingerprint covers the content of all files 'go list -deps' reports for the package and its dependencies within the
+// main module and local 'replace' targets, like '.go', cgo, assembler, '.syso' and embedded files, the 'go.mod' and 'go.sum'
+// files of these modules, the path and version of all other modules, the flags and environment defined by the options and the version of the go tool
+// - workDir: The directory the build runs in. Usually the module root directory
+// - packagePath: The package to build, relative to workDir. May be empty
+// - options: The BuildOptions of the build
+// It returns the hex encoded fingerprint and nil in case no error occur
+// In case of error the error and an empty string is returned
+func GetBuildFingerprint(workDir, packagePath string, options BuildOptions) (string, error) {
+	packages, errList := listBuildPackages(workDir, packagePath, options)
+	if errList != nil {
+		return "", errList
+	}
+
+	sourceFiles := []string{}
+	modules := []string{}
+	known := map[string]bool{}
+	for _, pack := range packages {
+		module := pack.Module
+		if module != nil && module.Replace != nil {
+			module = module.Replace
+		}
+
+		if module != nil && !pack.Module.Main && module.Version != "" {
+			if !known[module.Path] {
+				known[module.Path] = true
+				modules = append(modules, fmt.Sprintf("%s %s", module.Path, module.Version))
+			}
+			continue
+		}
+
+		for _, files := range [][]string{pack.GoFiles, pack.CgoFiles, pack.CFiles, pack.CXXFiles, pack.MFiles, pack.HFiles, pack.FFiles,
+			pack.SFiles, pack.SwigFiles, pack.SwigCXXFiles, pack.SysoFiles, pack.EmbedFiles} {
+			for _, file := range files {
+				sourceFiles = append(sourceFiles, filepath.Join(pack.Dir, file))
+			}
+		}
+		if module != nil && module.GoMod != "" && !known[module.GoMod] {
+			known[module.GoMod] = true
+			sourceFiles = append(sourceFiles, module.GoMod)
+			if goSum := filepath.Join(filepath.Dir(module.GoMod), "go.sum"); PathExists(goSum) {
+				sourceFiles = append(sourceFiles, goSum)
+			}
+		}
+	}
+	sort.Strings(sourceFiles)
+	sort.Strings(modules)
+
+	absWorkDir, errAbs := filepath.Abs(workDir)
+	if errAbs != nil {
+		return "", errAbs
+	}
+	hash := sha256.New()
+	for _, sourceFile := range sourceFiles {
+		relPath, errRel := filepath.Rel(absWorkDir, sourceFile)
+		if errRel != nil {
+			relPath = sourceFile
+		}
+		fmt.Fprintf(hash, "file %s\n", filepath.ToSlash(relPath))
+
+		file, errOpen := os.Open(sourceFile)
+		if errOpen != nil {
+			return "", errOpen
+		}
+		_, errCopy := io.Copy(hash, file)
+		file.Close()
+		if errCopy != nil {
+			return "", errCopy
+		}
+	}
+	for _, module := range modules {
+		fmt.Fprintf(hash, "module %s\n", module)
+	}
+
+	goEnv, errGoEnv := getGoEnv(workDir, options, "GOVERSION", "GOOS", "GOARCH")
+	if errGoEnv != nil {
+		return "", errGoEnv
+	}
+
+	fmt.Fprintf(hash, "package %s\n", packagePath)
+	fmt.Fprintf(hash, "args %s\n", strings.Join(getBuildArgs("", options), " "))
+	fmt.Fprintf(hash, "env %s\n", strings.Join(getBuildEnv(options), " "))
+	fmt.Fprintf(hash, "go %s\n", strings.Join(goEnv, " "))
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// listBuildPackages - Runs 'go list -deps -json' for the package to build, using the tags, module mode and environment of the build
+// - workDir: The directory the command runs in
+// - packagePath: The package to build, relative to workDir. May be empty
+// - options: The BuildOptions of the build
+// It returns all not standard library packages the build uses and nil in case no error occur
+// In case of error the error and an empty list is returned
+func listBuildPackages(workDir, packagePath string, options BuildOptions) ([]goListBuildPackage, error) {
+	args := []string{"list", "-deps", "-json"}
+	if options.Mod != "" {
+		args = append(args, fmt.Sprintf("-mod=%s", options.Mod))
+	}
+	if len(options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(options.Tags, ","))
+	}
+	if packagePath == "" {
+		packagePath = "."
+	}
+	args = append(args, packagePath)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = workDir
+	if env := getBuildEnv(options); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stderr = os.Stderr
+	output, errOutput := cmd.Output()
+	if errOutput != nil {
+		return []goListBuildPackage{}, fmt.Errorf("Error: 'go list' failed in '%s'. %w", workDir, errOutput)
+	}
+
+	packages := []goListBuildPackage{}
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		pack := goListBuildPackage{}
+		errDecode := decoder.Decode(&pack)
+		if errDecode == io.EOF {
+			break
+		}
+		if errDecode != nil {
+			return []goListBuildPackage{}, fmt.Errorf("Error: Can not read the 'go list' output of '%s'. %w", workDir, errDecode)
+		}
+		if !pack.Standard && pack.Dir != "" {
+			packages = append(packages, pack)
+		}
+	}
+
+	return packages, nil
+}
+
+// isBuildUpToDate - Check if the executable exists and the fingerprint stored next to it matches the given one
+// - outPutPath: The path of the executable
+// - fingerprint: The fingerprint of the upcoming build
+// It returns true if the build can be skipped
+func isBuildUpToDate(outPutPath, fingerprint string) bool {
+	if !PathExists(outPutPath) {
+		return false
+	}
+
+	storedFingerprint, err := os.ReadFile(getFingerprintPath(outPutPath))
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(string(storedFingerprint)) == fingerprint
+}
+
+// getFingerprintPath - Get the path of the file the fingerprint of an executable is stored in
+func getFingerprintPath(outPutPath string) string {
+	return fmt.Sprintf("%s.fingerprint", outPutPath)
+}
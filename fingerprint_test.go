@@ -0,0 +1,142 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGetBuildFingerprint(t *testing.T) {
+	packDir := filepath.Join(".", "testdata", "testProject", "main")
+	first, err := GetBuildFingerprint(packDir, "", BuildOptions{})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	second, err := GetBuildFingerprint(packDir, "", BuildOptions{})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if first != second {
+		t.Errorf("The fingerprint '%s' is not equal to '%s'", first, second)
+	}
+
+	withFlags, err := GetBuildFingerprint(packDir, "", BuildOptions{LdFlags: "-s"})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if first == withFlags {
+		t.Errorf("The fingerprint did not change with the ldflags")
+	}
+
+	_, err = GetBuildFingerprint(filepath.Join(".", "testdata", "not-existing-dir"), "", BuildOptions{})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
+
+func TestGetBuildFingerprintFileSets(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	files := map[string]string{
+		filepath.Join(baseDir, "tool", "go.mod"):   "module example.com/tool\n\ngo 1.18\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib\n",
+		filepath.Join(baseDir, "tool", "main.go"):  "package main\n\nimport (\n\t_ \"embed\"\n\n\t\"example.com/lib\"\n)\n\n//go:embed data.txt\nvar data string\n\nfunc main() {\n\tprintln(data, lib.Name())\n}\n",
+		filepath.Join(baseDir, "tool", "data.txt"): "first",
+		filepath.Join(baseDir, "lib", "go.mod"):    "module example.com/lib\n\ngo 1.18\n",
+		filepath.Join(baseDir, "lib", "lib.go"):    "package lib\n\nfunc Name() string {\n\treturn \"first\"\n}\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+	}
+
+	toolDir := filepath.Join(baseDir, "tool")
+	first, err := GetBuildFingerprint(toolDir, "", BuildOptions{})
+	if err != nil {
+		t.Fatalf("Got error '%s', but expected none", err.Error())
+	}
+	for _, changedFile := range []string{filepath.Join(toolDir, "data.txt"), filepath.Join(baseDir, "lib", "lib.go")} {
+		content, _ := os.ReadFile(changedFile)
+		if err := os.WriteFile(changedFile, append(content, []byte("\n// changed\n")...), 0644); err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+		changed, err := GetBuildFingerprint(toolDir, "", BuildOptions{})
+		if err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+		if changed == first {
+			t.Errorf("The fingerprint did not change with the file '%s'", changedFile)
+		}
+		first = changed
+	}
+
+	if err := os.WriteFile(filepath.Join(toolDir, "notes.md"), []byte("not part of the build"), 0644); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	unchanged, err := GetBuildFingerprint(toolDir, "", BuildOptions{})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if unchanged != first {
+		t.Errorf("The fingerprint changed with a file that is not part of the build")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestBuildSkipUnchanged(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+	binOutPath := filepath.Join(outDir, getExecutableName(filepath.Base(dirs[0]), runtime.GOOS))
+
+	options := BuildOptions{SkipUnchanged: true}
+	if errBuild := BuildFoldersWithOptions(dirs, outDir, options); errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+	if !PathExists(getFingerprintPath(binOutPath)) {
+		t.Errorf("The fingerprint '%s' was not created", getFingerprintPath(binOutPath))
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(binOutPath, past, past); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if errBuild := BuildFoldersWithOptions(dirs, outDir, options); errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+	info, _ := os.Stat(binOutPath)
+	if !info.ModTime().Equal(past) {
+		t.Errorf("The executable '%s' was build again, but should not", binOutPath)
+	}
+
+	options.LdFlags = "-s -w"
+	if errBuild := BuildFoldersWithOptions(dirs, outDir, options); errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+	info, _ = os.Stat(binOutPath)
+	if info.ModTime().Equal(past) {
+		t.Errorf("The executable '%s' was not build again, but should", binOutPath)
+	}
+
+	RemovePaths([]string{baseDir})
+}
@@ -0,0 +1,262 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultFuzzTime - The time budget of each fuzz target, when FuzzOptions.FuzzTime is not set
+const defaultFuzzTime = 10 * time.Second
+
+// FuzzTarget - A 'Fuzz*' function of a test package
+type FuzzTarget struct {
+	// The package directory the fuzz target is defined in
+	Dir  string
+	Name string
+}
+
+// FuzzOptions - The options of RunFuzzTargets
+type FuzzOptions struct {
+	// The time budget of each fuzz target, passed via '-fuzztime', 10s if '0'
+	FuzzTime time.Duration
+	// Build tags passed to the command via '-tags', may be empty
+	Tags []string
+	// Additional environment variables in the form 'key=value', may be empty
+	Env []string
+}
+
+// FuzzInput - A failing input found by the fuzzer
+type FuzzInput struct {
+	// The path of the corpus entry written by the fuzzer, within the 'testdata/fuzz' folder of the package
+	CorpusPath string
+	// The path of the copy of the corpus entry in the artifacts directory
+	ArtifactPath string
+	// The content of the corpus entry, in the 'go test fuzz v1' format
+	Content string
+}
+
+// FuzzResult - The result of one fuzz target
+type FuzzResult struct {
+	Target FuzzTarget
+	// The status of the fuzz target, TestStatusPass or TestStatusFail
+	Status   string
+	Duration time.Duration
+	// The failing inputs found by the fuzzer, empty if the fuzz target passed
+	FailingInputs []FuzzInput
+}
+
+// FindFuzzTargets - Find the 'Fuzz*' functions in the '*_test.go' files of the given packages
+// - packagesToTest: List of directory path that contains '*_test.go' files, like the ones returned by FindPackagesToTest
+// It returns the fuzz targets sorted by name within each package and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindFuzzTargets(packagesToTest []string) ([]FuzzTarget, error) {
+	targets := []FuzzTarget{}
+	fileSet := token.NewFileSet()
+	for _, packToTest := range packagesToTest {
+		testFiles, errGlob := filepath.Glob(filepath.Join(packToTest, "*_test.go"))
+		if errGlob != nil {
+			return []FuzzTarget{}, errGlob
+		}
+
+		names := []string{}
+		for _, testFile := range testFiles {
+			file, errParse := parser.ParseFile(fileSet, testFile, nil, parser.SkipObjectResolution)
+			if errParse != nil {
+				return []FuzzTarget{}, errParse
+			}
+			for _, decl := range file.Decls {
+				function, ok := decl.(*ast.FuncDecl)
+				if ok && isFuzzFunction(function) {
+					names = append(names, function.Name.Name)
+				}
+			}
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			targets = append(targets, FuzzTarget{Dir: packToTest, Name: name})
+		}
+	}
+
+	return targets, nil
+}
+
+// RunFuzzTargets - Runs 'go test -run ^$ -fuzz ^<name>$ -fuzztime <time>' for all given fuzz targets
+// When the fuzzer finds a failing input, it writes a new corpus entry into the 'testdata/fuzz/<name>' folder of the package.
+// These new entries are copied into '<artifactsDir>/<package>/<name>'. All fuzz targets will be executed, even if a fuzz target before failed
+// - targets: The fuzz targets to run, like the ones returned by FindFuzzTargets
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file
+// - artifactsDir: Path to the directory the failing inputs are copied to
+// - options: The FuzzOptions that define the flags and environment of the fuzzing
+// It returns the results of all fuzz targets and any error that may occur or an empty list
+func RunFuzzTargets(targets []FuzzTarget, logDir, logFileName, artifactsDir string, options FuzzOptions) ([]FuzzResult, []error) {
+	results := []FuzzResult{}
+	fuzzErrors := []error{}
+
+	if err := EnsureDirectoryExists(logDir); err != nil {
+		return results, append(fuzzErrors, err)
+	}
+
+	logPath := filepath.Join(logDir, logFileName)
+	logFile, errOpen := os.Create(logPath)
+	if errOpen != nil {
+		return results, append(fuzzErrors, errOpen)
+	}
+	defer logFile.Close()
+
+	for _, target := range targets {
+		fmt.Println(fmt.Sprintf("Fuzz '%s' of package '%s', logging to '%s'", target.Name, target.Dir, logPath))
+		corpusDir := filepath.Join(target.Dir, "testdata", "fuzz", target.Name)
+		knownEntries, errList := listCorpusEntries(corpusDir)
+		if errList != nil {
+			fmt.Fprintln(os.Stderr, errList)
+			fuzzErrors = append(fuzzErrors, errList)
+			continue
+		}
+
+		args := getFuzzArgs(target, options)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", target.Dir, "go", strings.Join(args, " "), logPath))
+		cmd := exec.Command("go", args...)
+		cmd.Dir = target.Dir
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
+		cmd.Stderr = logFile
+		cmd.Stdout = logFile
+		start := time.Now()
+		errFuzz := cmd.Run()
+
+		result := FuzzResult{Target: target, Status: TestStatusPass, Duration: time.Since(start), FailingInputs: []FuzzInput{}}
+		if errFuzz != nil {
+			result.Status = TestStatusFail
+			inputs, errCollect := collectFailingInputs(target, corpusDir, filepath.Join(artifactsDir, strings.TrimSuffix(getPackageLogName(target.Dir), ".log"), target.Name), knownEntries)
+			if errCollect != nil {
+				fmt.Fprintln(os.Stderr, errCollect)
+				fuzzErrors = append(fuzzErrors, errCollect)
+			}
+			result.FailingInputs = inputs
+			errFuzz = fmt.Errorf("Error: Fuzzing '%s' of package '%s' failed with %d failing inputs. %w", target.Name, target.Dir, len(inputs), errFuzz)
+			fmt.Fprintln(os.Stderr, errFuzz)
+			fuzzErrors = append(fuzzErrors, errFuzz)
+		}
+		results = append(results, result)
+	}
+
+	return results, fuzzErrors
+}
+
+// isFuzzFunction - Tell if the function is a fuzz target, like 'func FuzzXxx(f *testing.F)'
+func isFuzzFunction(function *ast.FuncDecl) bool {
+	name := function.Name.Name
+	if function.Recv != nil || !strings.HasPrefix(name, "Fuzz") {
+		return false
+	}
+	if len(name) > len("Fuzz") {
+		next, _ := utf8.DecodeRuneInString(name[len("Fuzz"):])
+		if unicode.IsLower(next) {
+			return false
+		}
+	}
+
+	params := function.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	pointer, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := pointer.X.(*ast.SelectorExpr)
+
+	return ok && selector.Sel.Name == "F"
+}
+
+// getFuzzArgs - Get the arguments for the 'go' command to run the fuzz target defined by the options
+func getFuzzArgs(target FuzzTarget, options FuzzOptions) []string {
+	fuzzTime := options.FuzzTime
+	if fuzzTime <= 0 {
+		fuzzTime = defaultFuzzTime
+	}
+
+	pattern := fmt.Sprintf("^%s$", regexp.QuoteMeta(target.Name))
+	args := []string{"test", "-run", "^$", "-fuzz", pattern, fmt.Sprintf("-fuzztime=%s", fuzzTime)}
+	if len(options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(options.Tags, ","))
+	}
+
+	return args
+}
+
+// listCorpusEntries - Get the names of the files in the corpus directory, an empty list if it does not exist
+func listCorpusEntries(corpusDir string) ([]string, error) {
+	if !PathExists(corpusDir) {
+		return []string{}, nil
+	}
+	entries, errRead := os.ReadDir(corpusDir)
+	if errRead != nil {
+		return []string{}, errRead
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// collectFailingInputs - Copy the corpus entries that are not part of knownEntries into the artifacts directory
+// - target: The fuzz target that failed
+// - corpusDir: The 'testdata/fuzz/<name>' folder of the fuzz target
+// - artifactsDir: The directory the new entries are copied to
+// - knownEntries: The corpus entries that existed before the fuzzer ran
+// It returns the new corpus entries and any error that may occur or nil
+func collectFailingInputs(target FuzzTarget, corpusDir, artifactsDir string, knownEntries []string) ([]FuzzInput, error) {
+	inputs := []FuzzInput{}
+	entries, errList := listCorpusEntries(corpusDir)
+	if errList != nil {
+		return inputs, errList
+	}
+
+	for _, entry := range entries {
+		if listContains(knownEntries, entry) {
+			continue
+		}
+		if err := os.MkdirAll(artifactsDir, 0755); err != nil {
+			return inputs, err
+		}
+
+		input := FuzzInput{CorpusPath: filepath.Join(corpusDir, entry), ArtifactPath: filepath.Join(artifactsDir, entry)}
+		content, errRead := os.ReadFile(input.CorpusPath)
+		if errRead != nil {
+			return inputs, errRead
+		}
+		if err := os.WriteFile(input.ArtifactPath, content, 0644); err != nil {
+			return inputs, err
+		}
+		input.Content = string(content)
+		fmt.Println(fmt.Sprintf("Failing input of '%s' copied to '%s'", target.Name, input.ArtifactPath))
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
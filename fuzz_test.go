@@ -0,0 +1,67 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindFuzzTargets(t *testing.T) {
+	fuzzDir := filepath.Join(".", "testdata", "testFuzzProject")
+	targets, err := FindFuzzTargets([]string{fuzzDir, filepath.Join(".", "testdata", "testProject", "main")})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(targets) != 2 || targets[0] != (FuzzTarget{Dir: fuzzDir, Name: "FuzzPrefix"}) || targets[1].Name != "FuzzPrefixLength" {
+		t.Errorf("Got the unexpected targets '%v'", targets)
+	}
+
+	targets, err = FindFuzzTargets([]string{filepath.Join(".", "testdata", "not-existing-dir")})
+	if err != nil || len(targets) != 0 {
+		t.Errorf("Got the unexpected targets '%v'", targets)
+	}
+}
+
+func TestRunFuzzTargets(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	fuzzDir := filepath.Join(".", "testdata", "testFuzzProject")
+	corpusDir := filepath.Join(fuzzDir, "testdata")
+	defer RemovePaths([]string{corpusDir})
+	artifactsDir := filepath.Join(baseDir, "artifacts")
+	targets := []FuzzTarget{{Dir: fuzzDir, Name: "FuzzPrefix"}, {Dir: fuzzDir, Name: "FuzzPrefixLength"}}
+	results, errFuzz := RunFuzzTargets(targets, baseDir, "Fuzz.log", artifactsDir, FuzzOptions{FuzzTime: 5 * time.Second})
+	if len(errFuzz) != 1 || !strings.Contains(errFuzz[0].Error(), "FuzzPrefixLength") {
+		t.Fatalf("Expected '1' error for 'FuzzPrefixLength', but got '%v'", errFuzz)
+	}
+	if len(results) != 2 || results[0].Status != TestStatusPass || results[1].Status != TestStatusFail {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+	if len(results[0].FailingInputs) != 0 || len(results[1].FailingInputs) != 1 {
+		t.Fatalf("Got the unexpected failing inputs '%v'", results[1].FailingInputs)
+	}
+
+	input := results[1].FailingInputs[0]
+	if filepath.Dir(input.ArtifactPath) != filepath.Join(artifactsDir, strings.TrimSuffix(getPackageLogName(fuzzDir), ".log"), "FuzzPrefixLength") {
+		t.Errorf("Got the unexpected artifact path '%s'", input.ArtifactPath)
+	}
+	content, errRead := os.ReadFile(input.ArtifactPath)
+	if errRead != nil {
+		t.Errorf("Got error '%s' but expected none", errRead.Error())
+	}
+	if string(content) != input.Content || !strings.HasPrefix(input.Content, "go test fuzz v1") {
+		t.Errorf("Got the unexpected content '%s'", input.Content)
+	}
+	if !PathExists(input.CorpusPath) {
+		t.Errorf("The corpus entry '%s' does not exist", input.CorpusPath)
+	}
+
+	RemovePaths([]string{baseDir})
+}
@@ -1 +1,3 @@
-module github.com/imker25/gobuildhelpers
\ No newline at end of file
+module github.com/imker25/gobuildhelpers
+
+go 1.18
@@ -9,7 +9,13 @@ package gobuildhelpers
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"io"
 	"io/ioutil"
 	"os"
@@ -18,6 +24,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type OsNotSupportedByThisMethod struct {
@@ -51,6 +59,7 @@ func RemovePaths(paths []string) error {
 // ConvertTestResults - Converts a given 'go test' output log and converts the content into a junit xml result file
 // It uses 'github.com/tebeka/go2xunit' to do so. You need to install this package before you can run this function.
 // To install the converter you might want to use the 'InstallTestConverter' function
+// To convert the results without any external tool use the 'ConvertTestLogToJUnit' function
 // - logPath: The path to the 'go test' output log to convert
 // - xmlResult: The junit xml result output file
 // - workDir: The directory this operation will run in. Usually the repository root directory
@@ -77,6 +86,7 @@ func ConvertTestResults(logPath, xmlResult, workDir string) error {
 }
 
 // InstallTestConverter - Install the  'github.com/tebeka/go2xunit' package used to convert test results in the 'ConvertTestResults' function
+// Not needed by the 'ConvertTestLogToJUnit' function
 // - workDir: The directory the package will be installed. Might not the repository root
 // It returns any error that may occur or nil
 func InstallTestConverter(workDir string) error {
@@ -227,6 +237,37 @@ func GetGitHeight(versionFile, workDir string) (int, error) {
 	return heightInt, nil
 }
 
+// TestOptions - Options passed to 'go test' by the '...WithOptions' and '...AndOptions' test functions, like RunTestFoldersWithOptions
+type TestOptions struct {
+	// Only run the tests matching the pattern, passed via '-run', may be empty
+	Run string
+	// Pass '-short' to the command when true
+	Short bool
+	// Number of runs of each test, passed via '-count', not set if '0'
+	Count int
+	// Maximal duration of the test binary, passed via '-timeout', not set if '0'
+	Timeout time.Duration
+	// Build tags passed to the command via '-tags', may be empty
+	Tags []string
+	// Randomize the test order, passed via '-shuffle', like 'on' or a seed, may be empty
+	Shuffle string
+	// List of GOMAXPROCS values, passed via '-cpu', like '1,2,4', may be empty
+	Cpu string
+	// Additional arguments passed to the command, may be empty
+	Args []string
+	// Additional environment variables in the form 'key=value', may be empty
+	Env []string
+	// Tell if the race detector is used, RaceDetectorAuto by default
+	// The coverage functions only use the race detector with RaceDetectorOn. All functions write into their log if it is used
+	Race RaceDetectorMode
+	// Maximal duration of the tests of a single package before the watchdog stops them, not set if '0'
+	// Unlike Timeout it stops the whole 'go test' command, see runCommandWithWatchdog
+	Watchdog time.Duration
+	// The context that stops the tests when it is done, like the context of a mage target that is cancelled on an interrupt, may be nil
+	// The running 'go test' command and its test binary are killed then and the function returns an error wrapping the context error
+	Context context.Context
+}
+
 // CoverTestFolders - Runs 'go test -v -cover' on all given packages to test and creates a log file with the output
 // Any package folder in the list should contain a go package with at least one '*_test.go' file
 // - packagesToCover: List of directory path that contains '*_test.go' files test coverage should be measured
@@ -234,6 +275,17 @@ func GetGitHeight(versionFile, workDir string) (int, error) {
 // - logFileName: Name of the log file
 // It returns any error that may occur or nil
 func CoverTestFolders(packagesToCover []string, logDir, logFileName string) error {
+	return CoverTestFoldersWithOptions(packagesToCover, logDir, logFileName, TestOptions{})
+}
+
+// CoverTestFoldersWithOptions - Runs 'go test -v -cover <options>' on all given packages to test and creates a log file with the output
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// - packagesToCover: List of directory path that contains '*_test.go' files test coverage should be measured
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file
+// - options: The TestOptions that define the flags and environment of the tests
+// It returns any error that may occur or nil
+func CoverTestFoldersWithOptions(packagesToCover []string, logDir, logFileName string, options TestOptions) error {
 	if err := EnsureDirectoryExists(logDir); err != nil {
 		return err
 	}
@@ -248,13 +300,21 @@ func CoverTestFolders(packagesToCover []string, logDir, logFileName string) erro
 	for _, packToTest := range packagesToCover {
 
 		fmt.Println(fmt.Sprintf("Measure test coverage for package '%s', logging to '%s'", packToTest, logPath))
-		fmt.Println(fmt.Sprintf("Run in %s: %s %s %s %s >> %s", packToTest, "go", "test", "-v", "-cover", logPath))
-		cmd := exec.Command("go", "test", "-v", "-cover")
+		args := append(append(getCoverRaceTestArgs("-v", options), "-cover"), getTestOptionArgs(options)...)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToTest, "go", strings.Join(args, " "), logPath))
+		fmt.Fprintln(logFile, getRaceLogHeader(packToTest, hasRaceArg(args)))
+		cmd := exec.Command("go", args...)
 
 		cmd.Dir = packToTest
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
 		cmd.Stderr = logFile
 		cmd.Stdout = logFile
-		errTest := cmd.Run()
+		timedOut, errTest := runCommandWithWatchdog(options.Context, cmd, options.Watchdog)
+		if timedOut {
+			errTest = NewTestTimedOut(packToTest, options.Watchdog)
+		}
 		if errTest != nil {
 			errTest = fmt.Errorf("Error: Coverage measurement of package '%s' failed. %w", packToTest, errTest)
 			fmt.Fprintln(os.Stderr, errTest)
@@ -265,7 +325,8 @@ func CoverTestFolders(packagesToCover []string, logDir, logFileName string) erro
 	return nil
 }
 
-// RunTestFolders - Runs 'go test -v -race' on linux and 'go test -v' on windows for all given packages to test
+// RunTestFolders - Runs 'go test -v [-race]' for all given packages to test
+// The race detector is used when GetRaceDetectorEnabled tells it is supported for the platform
 // Any package folder in the list should contain a go package with at least one '*_test.go' file
 // All tests will be executed, even if a error occur in the package before, the next package's tests get executed
 // - packagesToTest: List of directory path that contains '*_test.go' files to run
@@ -273,10 +334,23 @@ func CoverTestFolders(packagesToCover []string, logDir, logFileName string) erro
 // - logFileName: Name of the log file
 // It returns any error that may occur or an empty list
 func RunTestFolders(packagesToTest []string, logDir, logFileName string) []error {
-	return runTestFoldersEarlyExitPossible(packagesToTest, logDir, logFileName, false)
+	return runTestFoldersEarlyExitPossible(packagesToTest, logDir, logFileName, false, TestOptions{})
+}
+
+// RunTestFoldersWithOptions - Runs 'go test -v [-race] <options>' for all given packages to test
+// The race detector is used as defined by the Race field of the options, see GetRaceDetectorEnabled
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// All tests will be executed, even if a error occur in the package before, the next package's tests get executed
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file
+// - options: The TestOptions that define the flags and environment of the tests
+// It returns any error that may occur or an empty list
+func RunTestFoldersWithOptions(packagesToTest []string, logDir, logFileName string, options TestOptions) []error {
+	return runTestFoldersEarlyExitPossible(packagesToTest, logDir, logFileName, false, options)
 }
 
-// RunTestFolders - Runs 'go test -v -race' on linux and 'go test -v' on windows for all given packages to test
+// RunTestFolders - Runs 'go test -v [-race]' for all given packages to test
 // Any package folder in the list should contain a go package with at least one '*_test.go' file
 // Test execution will stop on first failed test
 // - packagesToTest: List of directory path that contains '*_test.go' files to run
@@ -284,7 +358,7 @@ func RunTestFolders(packagesToTest []string, logDir, logFileName string) []error
 // - logFileName: Name of the log file
 // It returns any error that may occur or nil
 func RunTestFoldersEarlyExit(packagesToTest []string, logDir, logFileName string) error {
-	testErrors := runTestFoldersEarlyExitPossible(packagesToTest, logDir, logFileName, true)
+	testErrors := runTestFoldersEarlyExitPossible(packagesToTest, logDir, logFileName, true, TestOptions{})
 
 	if len(testErrors) > 0 {
 		return testErrors[0]
@@ -293,15 +367,16 @@ func RunTestFoldersEarlyExit(packagesToTest []string, logDir, logFileName string
 	return nil
 }
 
-// RunTestFolders - Runs 'go test -v -race' on linux and 'go test -v' on windows for all given packages to test
+// RunTestFolders - Runs 'go test -v [-race]' for all given packages to test
 // Any package folder in the list should contain a go package with at least one '*_test.go' file
 // When earlyExit is false all tests will be executed, even if a error occur in the package before, the next package's tests get executed
 // - packagesToTest: List of directory path that contains '*_test.go' files to run
 // - logDir: Path to the directory the log file is crated
 // - logFileName: Name of the log file
 // - earlyExit: Tell if exit on first error or not
+// - options: The TestOptions that define the flags and environment of the tests
 // It returns any error that may occur or an empty list
-func runTestFoldersEarlyExitPossible(packagesToTest []string, logDir, logFileName string, earlyExit bool) []error {
+func runTestFoldersEarlyExitPossible(packagesToTest []string, logDir, logFileName string, earlyExit bool, options TestOptions) []error {
 	testErrors := []error{}
 
 	if err := EnsureDirectoryExists(logDir); err != nil {
@@ -318,18 +393,29 @@ func runTestFoldersEarlyExitPossible(packagesToTest []string, logDir, logFileNam
 	for _, packToTest := range packagesToTest {
 
 		fmt.Println(fmt.Sprintf("Test package '%s', logging to '%s'", packToTest, logPath))
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			fmt.Println(fmt.Sprintf("Run in %s: %s %s %s >> %s", packToTest, "go", "test", "-v", logPath))
-			cmd = exec.Command("go", "test", "-v")
-		} else {
-			fmt.Println(fmt.Sprintf("Run in %s: %s %s %s %s >> %s", packToTest, "go", "test", "-v", "-race", logPath))
-			cmd = exec.Command("go", "test", "-v", "-race")
+		args, errArgs := getRaceTestArgs(packToTest, "-v", options)
+		if errArgs != nil {
+			fmt.Fprintln(os.Stderr, errArgs)
+			testErrors = append(testErrors, errArgs)
+			if earlyExit {
+				return testErrors
+			}
+			continue
 		}
+		args = append(args, getTestOptionArgs(options)...)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToTest, "go", strings.Join(args, " "), logPath))
+		fmt.Fprintln(logFile, getRaceLogHeader(packToTest, hasRaceArg(args)))
+		cmd := exec.Command("go", args...)
 		cmd.Dir = packToTest
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
 		cmd.Stderr = logFile
 		cmd.Stdout = logFile
-		errTest := cmd.Run()
+		timedOut, errTest := runCommandWithWatchdog(options.Context, cmd, options.Watchdog)
+		if timedOut {
+			errTest = NewTestTimedOut(packToTest, options.Watchdog)
+		}
 		if errTest != nil {
 			errTest = fmt.Errorf("Error: Test of package '%s' failed. %w", packToTest, errTest)
 			fmt.Fprintln(os.Stderr, errTest)
@@ -343,6 +429,289 @@ func runTestFoldersEarlyExitPossible(packagesToTest []string, logDir, logFileNam
 	return testErrors
 }
 
+// getTestOptionArgs - Get the arguments for the 'go test' command defined by the options
+// - options: The TestOptions that define the flags of the tests
+// It returns the argument list, may be empty
+func getTestOptionArgs(options TestOptions) []string {
+	args := []string{}
+	if options.Run != "" {
+		args = append(args, "-run", options.Run)
+	}
+	if options.Short {
+		args = append(args, "-short")
+	}
+	if options.Count > 0 {
+		args = append(args, fmt.Sprintf("-count=%d", options.Count))
+	}
+	if options.Timeout > 0 {
+		args = append(args, fmt.Sprintf("-timeout=%s", options.Timeout))
+	}
+	if len(options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(options.Tags, ","))
+	}
+	if options.Shuffle != "" {
+		args = append(args, fmt.Sprintf("-shuffle=%s", options.Shuffle))
+	}
+	if options.Cpu != "" {
+		args = append(args, fmt.Sprintf("-cpu=%s", options.Cpu))
+	}
+
+	return append(args, options.Args...)
+}
+
+// TestLogIndexFileName - The name of the index file RunTestFoldersParallel writes into the log directory
+const TestLogIndexFileName = "TestLogIndex.txt"
+
+// TestLogIndexEntry - One line of the index file written by RunTestFoldersParallel
+type TestLogIndexEntry struct {
+	Package string
+	// The status of the package, 'PASS' or 'FAIL'
+	Status   string
+	Duration time.Duration
+	// The name of the package's log file within the log directory
+	LogFile string
+	// Tell if the tests ran with the race detector, always false if the index was written without this column
+	RaceDetector bool
+}
+
+// RunTestFoldersParallel - Runs 'go test -v [-race]' for all given packages to test concurrently
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// Each package gets its own log file, named after the package path, in logDir. All tests will be executed, even if a error occur in another package
+// The 'TestLogIndex.txt' file in logDir lists the status, the duration, the package, the log file name and if the race detector ran for each package, separated by tabs
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log files are crated
+// - maxWorkers: The maximal number of packages tested at the same time. If '0' or less the number of CPUs is used
+// It returns any error that may occur, in the order of the packagesToTest, or an empty list
+func RunTestFoldersParallel(packagesToTest []string, logDir string, maxWorkers int) []error {
+	return RunTestFoldersParallelWithOptions(packagesToTest, logDir, maxWorkers, TestOptions{})
+}
+
+// RunTestFoldersParallelWithOptions - Runs 'go test -v [-race] <options>' for all given packages to test concurrently
+// The race detector is used as defined by the Race field of the options, see GetRaceDetectorEnabled
+// Each package gets its own log file and the 'TestLogIndex.txt' file in logDir lists them, like RunTestFoldersParallel does
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log files are crated
+// - maxWorkers: The maximal number of packages tested at the same time. If '0' or less the number of CPUs is used
+// - options: The TestOptions that define the flags, environment, watchdog and context of the tests
+// It returns any error that may occur, in the order of the packagesToTest, or an empty list
+func RunTestFoldersParallelWithOptions(packagesToTest []string, logDir string, maxWorkers int, options TestOptions) []error {
+	if err := EnsureDirectoryExists(logDir); err != nil {
+		return []error{err}
+	}
+
+	if maxWorkers <= 0 {
+		maxWorkers = runtime.NumCPU()
+	}
+
+	entries := make([]TestLogIndexEntry, len(packagesToTest))
+	packageErrors := make([]error, len(packagesToTest))
+	var waitGroup sync.WaitGroup
+	workers := make(chan struct{}, maxWorkers)
+
+	for i, packToTest := range packagesToTest {
+		waitGroup.Add(1)
+		workers <- struct{}{}
+		go func(index int, packToTest string) {
+			defer waitGroup.Done()
+			defer func() { <-workers }()
+
+			entries[index] = TestLogIndexEntry{Package: packToTest, Status: "PASS", LogFile: getPackageLogName(packToTest)}
+			start := time.Now()
+			entries[index].RaceDetector, packageErrors[index] = runTestFolderToLog(packToTest, filepath.Join(logDir, entries[index].LogFile), options)
+			entries[index].Duration = time.Since(start)
+			if packageErrors[index] != nil {
+				entries[index].Status = "FAIL"
+			}
+		}(i, packToTest)
+	}
+	waitGroup.Wait()
+
+	testErrors := []error{}
+	for _, err := range packageErrors {
+		if err != nil {
+			testErrors = append(testErrors, err)
+		}
+	}
+
+	if errIndex := writeTestLogIndex(filepath.Join(logDir, TestLogIndexFileName), entries); errIndex != nil {
+		testErrors = append(testErrors, errIndex)
+	}
+
+	return testErrors
+}
+
+// ReadTestLogIndex - Read the index file written by RunTestFoldersParallel
+// - indexPath: The path to the index file
+// It returns the entries of the index and nil in case no error occur
+// In case of error the error and an empty list is returned
+func ReadTestLogIndex(indexPath string) ([]TestLogIndexEntry, error) {
+	byteContent, errRead := os.ReadFile(indexPath)
+	if errRead != nil {
+		return []TestLogIndexEntry{}, errRead
+	}
+
+	entries := []TestLogIndexEntry{}
+	for i, line := range strings.Split(string(byteContent), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 && len(fields) != 5 {
+			return []TestLogIndexEntry{}, fmt.Errorf("Error: Line %d of '%s' is not in the form 'status<tab>duration<tab>package<tab>log file[<tab>race detector]'", i+1, indexPath)
+		}
+		duration, errDuration := time.ParseDuration(fields[1])
+		if errDuration != nil {
+			return []TestLogIndexEntry{}, fmt.Errorf("Error: Line %d of '%s' has an invalid duration. %w", i+1, indexPath, errDuration)
+		}
+		entry := TestLogIndexEntry{Status: fields[0], Duration: duration, Package: fields[2], LogFile: fields[3]}
+		if len(fields) == 5 {
+			race, errRace := strconv.ParseBool(fields[4])
+			if errRace != nil {
+				return []TestLogIndexEntry{}, fmt.Errorf("Error: Line %d of '%s' has an invalid race detector value. %w", i+1, indexPath, errRace)
+			}
+			entry.RaceDetector = race
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// runTestFolderToLog - Runs 'go test -v [-race] <options>' in the given package directory and writes the output into its own log file
+// - packToTest: The package directory to run the tests in
+// - logPath: The path of the log file to create
+// - options: The TestOptions that define the flags, environment, watchdog and context of the tests
+// It returns true if the race detector was used and any error that may occur or nil
+func runTestFolderToLog(packToTest, logPath string, options TestOptions) (bool, error) {
+	logFile, errOpen := os.Create(logPath)
+	if errOpen != nil {
+		return false, errOpen
+	}
+	defer logFile.Close()
+
+	fmt.Println(fmt.Sprintf("Test package '%s', logging to '%s'", packToTest, logPath))
+	args, errArgs := getRaceTestArgs(packToTest, "-v", options)
+	if errArgs != nil {
+		fmt.Fprintln(os.Stderr, errArgs)
+		return false, errArgs
+	}
+	args = append(args, getTestOptionArgs(options)...)
+	race := hasRaceArg(args)
+	fmt.Println(fmt.Sprintf("Run in %s: %s %s > %s", packToTest, "go", strings.Join(args, " "), logPath))
+	fmt.Fprintln(logFile, getRaceLogHeader(packToTest, race))
+	cmd := exec.Command("go", args...)
+	cmd.Dir = packToTest
+	if len(options.Env) > 0 {
+		cmd.Env = append(os.Environ(), options.Env...)
+	}
+	cmd.Stderr = logFile
+	cmd.Stdout = logFile
+	timedOut, errTest := runCommandWithWatchdog(options.Context, cmd, options.Watchdog)
+	if timedOut {
+		errTest = NewTestTimedOut(packToTest, options.Watchdog)
+	}
+	if errTest != nil {
+		errTest = fmt.Errorf("Error: Test of package '%s' failed. %w", packToTest, errTest)
+		fmt.Fprintln(os.Stderr, errTest)
+		return race, errTest
+	}
+
+	return race, nil
+}
+
+// writeTestLogIndex - Write the index file of RunTestFoldersParallel
+func writeTestLogIndex(indexPath string, entries []TestLogIndexEntry) error {
+	lines := []string{}
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s\t%s\t%t", entry.Status, entry.Duration, entry.Package, entry.LogFile, entry.RaceDetector))
+	}
+
+	return os.WriteFile(indexPath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// getPackageLogName - Get the name of the log file for a package, derived from the package path
+// The readable part of the name is followed by a short hash of the absolute package path,
+// so paths like '../pkg' and 'pkg' or 'a/b' and 'a_b' get different names
+// - packToTest: The package directory path
+// It returns a file name like 'testdata_testProject_main_1a2b3c4d.log'
+func getPackageLogName(packToTest string) string {
+	name := filepath.ToSlash(filepath.Clean(packToTest))
+	for strings.HasPrefix(name, "../") {
+		name = strings.TrimPrefix(name, "../")
+	}
+	name = strings.TrimPrefix(name, "/")
+	if name == "." || name == ".." || name == "" {
+		name = "root"
+	}
+	name = strings.NewReplacer("/", "_", ":", "_").Replace(name)
+
+	fullPath, errAbs := filepath.Abs(packToTest)
+	if errAbs != nil {
+		fullPath = filepath.Clean(packToTest)
+	}
+	hash := sha256.Sum256([]byte(filepath.ToSlash(fullPath)))
+
+	return fmt.Sprintf("%s_%s.log", name, hex.EncodeToString(hash[:4]))
+}
+
+// getTestArgs - Get the arguments for the 'go' command to run the tests of a package
+// - outputFlag: The flag that defines the output of the command, like '-v' or '-json'
+// - race: Tell if '-race' is added
+// It returns the argument list, starting with 'test'
+func getTestArgs(outputFlag string, race bool) []string {
+	if !race {
+		return []string{"test", outputFlag}
+	}
+
+	return []string{"test", outputFlag, "-race"}
+}
+
+// BuildTarget - A GOOS/GOARCH combination a package can be compiled for
+type BuildTarget struct {
+	OS   string
+	Arch string
+}
+
+// String - Get the name of the target in the form '<os>_<arch>', as used for the output directories
+func (t BuildTarget) String() string {
+	return fmt.Sprintf("%s_%s", t.OS, t.Arch)
+}
+
+// BuildTargetResult - The result of the compilation of one package for one BuildTarget
+type BuildTargetResult struct {
+	Package    string
+	Target     BuildTarget
+	OutputPath string
+	Err        error
+}
+
+// BuildOptions - Options passed to 'go build' by the BuildFoldersWithOptions function
+type BuildOptions struct {
+	// Flags passed to the command via '-ldflags', may be empty
+	LdFlags string
+	// Flags passed to the command via '-gcflags', may be empty
+	GcFlags string
+	// Build tags passed to the command via '-tags', may be empty
+	Tags []string
+	// Pass '-trimpath' to the command when true
+	TrimPath bool
+	// Build mode passed to the command via '-buildmode', may be empty
+	BuildMode string
+	// Module download mode passed to the command via '-mod', e.g. 'vendor', may be empty
+	Mod string
+	// Set 'CGO_ENABLED=0' in the environment of the build when true
+	DisableCgo bool
+	// Additional environment variables in the form 'key=value', may be empty
+	Env []string
+	// Skip the build when the fingerprint stored next to the executable is unchanged and the executable exists
+	// See GetBuildFingerprint for what is part of the fingerprint
+	SkipUnchanged bool
+	// Path of a JSON BuildManifest file, written after all executables are build, may be empty
+	ManifestPath string
+}
+
 // BuildFolders - Runs 'go build -o <binDir>/packageName -v -ldflags <ldfFlags>' for all given packages to build
 // Any package folder in the list should contain a go package with a 'go.mod' file
 // - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
@@ -350,38 +719,406 @@ func runTestFoldersEarlyExitPossible(packagesToTest []string, logDir, logFileNam
 // - ldfFlags: Flags passed to the command via '-ldflags', may be empty
 // It returns any error that may occur or nil
 func BuildFolders(packagesToBuild []string, binDir, ldfFlags string) error {
+	return BuildFoldersWithOptions(packagesToBuild, binDir, BuildOptions{LdFlags: ldfFlags})
+}
+
+// BuildFoldersWithOptions - Runs 'go build -o <binDir>/packageName -v <options>' for all given packages to build
+// Any package folder in the list should contain a go package with a 'go.mod' file
+// - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
+// - binDir: The output directory of the build. Any package to build will create an executable there
+// - options: The BuildOptions that define the flags and environment of the build
+// It returns any error that may occur or nil
+func BuildFoldersWithOptions(packagesToBuild []string, binDir string, options BuildOptions) error {
 	if err := EnsureDirectoryExists(binDir); err != nil {
 		return err
 	}
 
+	manifest := BuildManifest{Artifacts: []BuildManifestEntry{}}
 	for _, packToBuild := range packagesToBuild {
-		outPutPath := filepath.Join(binDir, filepath.Base(packToBuild))
-		if runtime.GOOS == "windows" {
-			outPutPath = fmt.Sprintf("%s.exe", outPutPath)
-		}
-		fmt.Println(fmt.Sprintf("Compile package '%s' to '%s'", packToBuild, outPutPath))
-
-		var cmd *exec.Cmd
-		if ldfFlags == "" {
-			fmt.Println(fmt.Sprintf("Run in %s: %s %s %s %s %s ", packToBuild, "go", "build", "-o", outPutPath, "-v"))
-			cmd = exec.Command("go", "build", "-o", outPutPath, "-v")
-		} else {
-			fmt.Println(fmt.Sprintf("Run in %s: %s %s %s %s %s -ldflags=\"%s\"", packToBuild, "go", "build", "-o", outPutPath, "-v", ldfFlags))
-			cmd = exec.Command("go", "build", "-o", outPutPath, "-v", "-ldflags", ldfFlags)
-		}
-		cmd.Dir = packToBuild
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		errBuild := cmd.Run()
-		if errBuild != nil {
-			errBuild = fmt.Errorf("Error: Build of package '%s' failed. %w", packToBuild, errBuild)
-			fmt.Fprintln(os.Stderr, errBuild)
+		outPutPath := filepath.Join(binDir, getExecutableName(filepath.Base(packToBuild), getTargetOS(options)))
+		start := time.Now()
+		if errBuild := buildPackage(packToBuild, outPutPath, options, os.Stdout, os.Stderr); errBuild != nil {
 			return errBuild
 		}
+		if options.ManifestPath != "" {
+			entry, errEntry := getBuildManifestEntry(packToBuild, packToBuild, outPutPath, options, time.Since(start))
+			if errEntry != nil {
+				return errEntry
+			}
+			manifest.Artifacts = append(manifest.Artifacts, entry)
+		}
+	}
+
+	if options.ManifestPath != "" {
+		return WriteBuildManifest(options.ManifestPath, manifest)
 	}
 	return nil
 }
 
+// BuildFoldersForTargets - Runs 'go build -o <binDir>/<os>_<arch>/packageName -v -ldflags <ldfFlags>' for all given packages and targets
+// GOOS and GOARCH are set in the environment of the build for each target. Executables for windows targets get the '.exe' suffix
+// All builds will be executed, even if the build of a package or target before failed
+// - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
+// - binDir: The output directory of the build. Each target will get a '<os>_<arch>' sub directory there
+// - ldfFlags: Flags passed to the command via '-ldflags', may be empty
+// - targets: List of the GOOS/GOARCH combinations to build for
+// It returns a result for each package and target combination, failed builds have the Err field set
+func BuildFoldersForTargets(packagesToBuild []string, binDir, ldfFlags string, targets []BuildTarget) []BuildTargetResult {
+	results, _ := BuildFoldersForTargetsWithOptions(packagesToBuild, binDir, BuildOptions{LdFlags: ldfFlags}, targets)
+
+	return results
+}
+
+// BuildFoldersForTargetsWithOptions - Runs 'go build -o <binDir>/<os>_<arch>/packageName -v <options>' for all given packages and targets
+// GOOS and GOARCH of the target are added to the environment of the options. Executables for windows targets get the '.exe' suffix
+// All builds will be executed, even if the build of a package or target before failed
+// If options.ManifestPath is set, the manifest gets one entry for each successful package and target combination
+// - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
+// - binDir: The output directory of the build. Each target will get a '<os>_<arch>' sub directory there
+// - options: The BuildOptions that define the flags and environment of the build
+// - targets: List of the GOOS/GOARCH combinations to build for
+// It returns a result for each package and target combination, failed builds have the Err field set,
+// and any error that may occur when writing the manifest or nil
+func BuildFoldersForTargetsWithOptions(packagesToBuild []string, binDir string, options BuildOptions, targets []BuildTarget) ([]BuildTargetResult, error) {
+	results := []BuildTargetResult{}
+	manifest := BuildManifest{Artifacts: []BuildManifestEntry{}}
+
+	for _, target := range targets {
+		targetDir := filepath.Join(binDir, target.String())
+		errDir := EnsureDirectoryExists(binDir)
+		if errDir == nil {
+			errDir = EnsureDirectoryExists(targetDir)
+		}
+		targetOptions := options
+		targetOptions.Env = append(append([]string{}, options.Env...), fmt.Sprintf("GOOS=%s", target.OS), fmt.Sprintf("GOARCH=%s", target.Arch))
+
+		for _, packToBuild := range packagesToBuild {
+			result := BuildTargetResult{
+				Package:    packToBuild,
+				Target:     target,
+				OutputPath: filepath.Join(targetDir, getExecutableName(filepath.Base(packToBuild), target.OS)),
+				Err:        errDir,
+			}
+			if result.Err == nil {
+				start := time.Now()
+				result.Err = buildPackage(packToBuild, result.OutputPath, targetOptions, os.Stdout, os.Stderr)
+				if result.Err == nil && options.ManifestPath != "" {
+					entry, errEntry := getBuildManifestEntry(packToBuild, packToBuild, result.OutputPath, targetOptions, time.Since(start))
+					if errEntry == nil {
+						manifest.Artifacts = append(manifest.Artifacts, entry)
+					}
+					result.Err = errEntry
+				}
+			}
+			results = append(results, result)
+		}
+	}
+
+	if options.ManifestPath != "" {
+		return results, WriteBuildManifest(options.ManifestPath, manifest)
+	}
+	return results, nil
+}
+
+// BuildFoldersParallel - Runs 'go build -o <binDir>/packageName -v -ldflags <ldfFlags>' for all given packages to build concurrently
+// The output of each build is buffered and printed once the package is done, so the logs of the packages don't interleave
+// All builds will be executed, even if the build of another package failed
+// - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
+// - binDir: The output directory of the build. Any package to build will create an executable there
+// - ldfFlags: Flags passed to the command via '-ldflags', may be empty
+// - maxWorkers: The maximal number of builds running at the same time. If '0' or less the number of CPUs is used
+// It returns any error that may occur, in the order of the packagesToBuild, or an empty list
+func BuildFoldersParallel(packagesToBuild []string, binDir, ldfFlags string, maxWorkers int) []error {
+	return BuildFoldersParallelWithOptions(packagesToBuild, binDir, BuildOptions{LdFlags: ldfFlags}, maxWorkers)
+}
+
+// BuildFoldersParallelWithOptions - Runs 'go build -o <binDir>/packageName -v <options>' for all given packages to build concurrently
+// The output of each build is buffered and printed once the package is done, so the logs of the packages don't interleave
+// All builds will be executed, even if the build of another package failed
+// If options.ManifestPath is set, the manifest gets one entry for each successful build, in the order of the packagesToBuild
+// - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
+// - binDir: The output directory of the build. Any package to build will create an executable there
+// - options: The BuildOptions that define the flags and environment of the build
+// - maxWorkers: The maximal number of builds running at the same time. If '0' or less the number of CPUs is used
+// It returns any error that may occur, in the order of the packagesToBuild, or an empty list
+func BuildFoldersParallelWithOptions(packagesToBuild []string, binDir string, options BuildOptions, maxWorkers int) []error {
+	if err := EnsureDirectoryExists(binDir); err != nil {
+		return []error{err}
+	}
+
+	if maxWorkers <= 0 {
+		maxWorkers = runtime.NumCPU()
+	}
+
+	packageErrors := make([]error, len(packagesToBuild))
+	entries := make([]*BuildManifestEntry, len(packagesToBuild))
+	var outputLock sync.Mutex
+	var waitGroup sync.WaitGroup
+	workers := make(chan struct{}, maxWorkers)
+
+	for i, packToBuild := range packagesToBuild {
+		waitGroup.Add(1)
+		workers <- struct{}{}
+		go func(index int, packToBuild string) {
+			defer waitGroup.Done()
+			defer func() { <-workers }()
+
+			var output bytes.Buffer
+			outPutPath := filepath.Join(binDir, getExecutableName(filepath.Base(packToBuild), getTargetOS(options)))
+			start := time.Now()
+			packageErrors[index] = buildPackage(packToBuild, outPutPath, options, &output, &output)
+			if packageErrors[index] == nil && options.ManifestPath != "" {
+				entry, errEntry := getBuildManifestEntry(packToBuild, packToBuild, outPutPath, options, time.Since(start))
+				entries[index] = &entry
+				packageErrors[index] = errEntry
+			}
+
+			outputLock.Lock()
+			defer outputLock.Unlock()
+			fmt.Print(output.String())
+		}(i, packToBuild)
+	}
+	waitGroup.Wait()
+
+	buildErrors := []error{}
+	manifest := BuildManifest{Artifacts: []BuildManifestEntry{}}
+	for i, err := range packageErrors {
+		if err != nil {
+			buildErrors = append(buildErrors, err)
+		} else if entries[i] != nil {
+			manifest.Artifacts = append(manifest.Artifacts, *entries[i])
+		}
+	}
+
+	if options.ManifestPath != "" {
+		if err := WriteBuildManifest(options.ManifestPath, manifest); err != nil {
+			buildErrors = append(buildErrors, err)
+		}
+	}
+
+	return buildErrors
+}
+
+// buildPackage - Runs 'go build -o <outPutPath> -v <options>' in the given package directory
+// - packToBuild: The package directory path to build
+// - outPutPath: The path of the executable to create
+// - options: The BuildOptions that define the flags and environment of the build
+// - stdOut: The writer the build messages and the output of the command are written to
+// - stdErr: The writer the build errors and the error output of the command are written to
+// It returns any error that may occur or nil
+func buildPackage(packToBuild, outPutPath string, options BuildOptions, stdOut, stdErr io.Writer) error {
+	return buildPackageInDir(packToBuild, "", outPutPath, options, stdOut, stdErr)
+}
+
+// buildPackageInDir - Runs 'go build -o <outPutPath> -v <options> <packagePath>' in the given working directory
+// - workDir: The directory the command runs in
+// - packagePath: The package to build, relative to workDir like './cmd/tool'. If empty the package in workDir is build
+// - outPutPath: The path of the executable to create
+// - options: The BuildOptions that define the flags and environment of the build
+// - stdOut: The writer the build messages and the output of the command are written to
+// - stdErr: The writer the build errors and the error output of the command are written to
+// It returns any error that may occur or nil
+func buildPackageInDir(workDir, packagePath, outPutPath string, options BuildOptions, stdOut, stdErr io.Writer) error {
+	packToBuild := workDir
+	if packagePath != "" {
+		packToBuild = filepath.Join(workDir, packagePath)
+	}
+	fingerprint := ""
+	if options.SkipUnchanged {
+		// The fingerprint is taken before the build, so changes made while the build runs are found by the next build
+		var errFingerprint error
+		fingerprint, errFingerprint = GetBuildFingerprint(workDir, packagePath, options)
+		if errFingerprint != nil {
+			return errFingerprint
+		}
+		if isBuildUpToDate(outPutPath, fingerprint) {
+			fmt.Fprintln(stdOut, fmt.Sprintf("Skip compilation of package '%s', '%s' is up to date", packToBuild, outPutPath))
+			return nil
+		}
+	}
+	fmt.Fprintln(stdOut, fmt.Sprintf("Compile package '%s' to '%s'", packToBuild, outPutPath))
+
+	args := getBuildArgs(outPutPath, options)
+	if packagePath != "" {
+		args = append(args, packagePath)
+	}
+	fmt.Fprintln(stdOut, fmt.Sprintf("Run in %s: %s %s", workDir, "go", strings.Join(args, " ")))
+	cmd := exec.Command("go", args...)
+	cmd.Dir = workDir
+	if env := getBuildEnv(options); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = stdOut
+	cmd.Stderr = stdErr
+	errBuild := cmd.Run()
+	if errBuild != nil {
+		errBuild = fmt.Errorf("Error: Build of package '%s' failed. %w", packToBuild, errBuild)
+		fmt.Fprintln(stdErr, errBuild)
+		return errBuild
+	}
+
+	if options.SkipUnchanged {
+		return os.WriteFile(getFingerprintPath(outPutPath), []byte(fingerprint), 0644)
+	}
+
+	return nil
+}
+
+// getTargetOS - Get the operating system a build with the given options is for
+// - options: The BuildOptions that define the environment of the build
+// It returns the last GOOS set in the options environment, or runtime.GOOS if there is none
+func getTargetOS(options BuildOptions) string {
+	goos := runtime.GOOS
+	for _, variable := range options.Env {
+		if strings.HasPrefix(variable, "GOOS=") {
+			goos = strings.TrimPrefix(variable, "GOOS=")
+		}
+	}
+
+	return goos
+}
+
+// getBuildArgs - Get the arguments for the 'go' command to build an executable with the given options
+// - outPutPath: The path of the executable to create
+// - options: The BuildOptions that define the flags of the build
+// It returns the argument list, starting with 'build'
+func getBuildArgs(outPutPath string, options BuildOptions) []string {
+	args := []string{"build", "-o", outPutPath, "-v"}
+	if options.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	if options.BuildMode != "" {
+		args = append(args, fmt.Sprintf("-buildmode=%s", options.BuildMode))
+	}
+	if options.Mod != "" {
+		args = append(args, fmt.Sprintf("-mod=%s", options.Mod))
+	}
+	if len(options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(options.Tags, ","))
+	}
+	if options.GcFlags != "" {
+		args = append(args, "-gcflags", options.GcFlags)
+	}
+	if options.LdFlags != "" {
+		args = append(args, "-ldflags", options.LdFlags)
+	}
+
+	return args
+}
+
+// getBuildEnv - Get the additional environment variables for a build with the given options
+// - options: The BuildOptions that define the environment of the build
+// It returns the list of variables in the form 'key=value', may be empty
+func getBuildEnv(options BuildOptions) []string {
+	env := []string{}
+	if options.DisableCgo {
+		env = append(env, "CGO_ENABLED=0")
+	}
+
+	return append(env, options.Env...)
+}
+
+// getExecutableName - Get the file name of an executable for the given operating system
+// - name: The name of the executable without any suffix
+// - goos: The GOOS the executable is build for
+// It returns the name with '.exe' suffix for windows and the unchanged name otherwise
+func getExecutableName(name, goos string) string {
+	if goos == "windows" {
+		return fmt.Sprintf("%s.exe", name)
+	}
+
+	return name
+}
+
+// BuildMainPackages - Runs 'go build -o <binDir>/<dirName> -v <options> ./<packagePath>' for all main packages found by FindMainPackages
+// The commands run in the module root directory, so a single module may contain many executables, e.g. in a 'cmd/<tool>/main.go' layout
+// - moduleDir: The root directory of the module, that contains the 'go.mod' file
+// - binDir: The output directory of the build. Any main package will create an executable named after its directory there
+// - options: The BuildOptions that define the flags and environment of the build
+// It returns any error that may occur or nil
+func BuildMainPackages(moduleDir, binDir string, options BuildOptions) error {
+	mainPackages, errFind := FindMainPackages(moduleDir)
+	if errFind != nil {
+		return errFind
+	}
+
+	if err := EnsureDirectoryExists(binDir); err != nil {
+		return err
+	}
+
+	manifest := BuildManifest{Artifacts: []BuildManifestEntry{}}
+	for _, mainPackage := range mainPackages {
+		relPath, errRel := filepath.Rel(moduleDir, mainPackage)
+		if errRel != nil {
+			return errRel
+		}
+		outPutPath := filepath.Join(binDir, getExecutableName(filepath.Base(mainPackage), getTargetOS(options)))
+		packagePath := "." + string(filepath.Separator) + relPath
+		start := time.Now()
+		if errBuild := buildPackageInDir(moduleDir, packagePath, outPutPath, options, os.Stdout, os.Stderr); errBuild != nil {
+			return errBuild
+		}
+		if options.ManifestPath != "" {
+			entry, errEntry := getBuildManifestEntry(moduleDir, mainPackage, outPutPath, options, time.Since(start))
+			if errEntry != nil {
+				return errEntry
+			}
+			manifest.Artifacts = append(manifest.Artifacts, entry)
+		}
+	}
+
+	if options.ManifestPath != "" {
+		return WriteBuildManifest(options.ManifestPath, manifest)
+	}
+	return nil
+}
+
+// FindMainPackages - Find a list of folders within a module that contain a 'package main'
+// Like the go tool, folders named 'testdata' or 'vendor', folders starting with '.' or '_' and nested modules are skipped
+// - moduleDir: The root directory of the module, that contains the 'go.mod' file
+// It returns the list of directory paths and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindMainPackages(moduleDir string) ([]string, error) {
+	mainPackages := []string{}
+	fileSet := token.NewFileSet()
+	errFindMain := filepath.Walk(moduleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == moduleDir {
+				return nil
+			}
+			name := info.Name()
+			if name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || PathExists(filepath.Join(path, "go.mod")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		packDir := filepath.Dir(path)
+		if filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") || listContains(mainPackages, packDir) {
+			return nil
+		}
+
+		file, errParse := parser.ParseFile(fileSet, path, nil, parser.PackageClauseOnly)
+		if errParse != nil {
+			return errParse
+		}
+		if file.Name.Name == "main" {
+			mainPackages = append(mainPackages, packDir)
+		}
+
+		return nil
+	})
+	if errFindMain != nil {
+		return []string{}, errFindMain
+	}
+
+	return mainPackages, nil
+}
+
 // FindPackagesToBuild - Find a list of folders that contain go packages
 // - sourceDir: The directory this function will start to search in recursively
 // It returns the list of directory paths and nil in case of no error
@@ -487,6 +1224,25 @@ func PathExists(path string) bool {
 	return true
 }
 
+// GetFileSha256 - Get the SHA-256 hash of a file
+// - path: The path of the file to hash
+// It returns the hex encoded hash and nil in case no error occur
+// In case of error the error and an empty string is returned
+func GetFileSha256(path string) (string, error) {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return "", errOpen
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func listContains(list []string, value string) bool {
 	for _, item := range list {
 		if item == value {
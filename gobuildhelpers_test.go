@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 const baseDir = "tmp"
@@ -256,6 +257,216 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+func TestBuildForTargets(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+
+	targets := []BuildTarget{{OS: "linux", Arch: "arm64"}, {OS: "windows", Arch: "amd64"}}
+	results := BuildFoldersForTargets(dirs, outDir, "", targets)
+	if len(results) != 2 {
+		t.Errorf("Expected '2' build results, but got '%d'", len(results))
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("Got error '%s' for target '%s', but expected none", result.Err.Error(), result.Target)
+		}
+		if !PathExists(result.OutputPath) {
+			t.Errorf("Executable '%s', was not created", result.OutputPath)
+		}
+	}
+
+	expectedWinPath := filepath.Join(outDir, "windows_amd64", "main.exe")
+	if results[1].OutputPath != expectedWinPath {
+		t.Errorf("Expected the output path '%s', but got '%s'", expectedWinPath, results[1].OutputPath)
+	}
+
+	sizes, errSizes := GetBinarySizes(outDir)
+	if errSizes != nil {
+		t.Errorf("Got error '%s', but expected none", errSizes.Error())
+	}
+	if sizes["linux_arm64/main"] <= 0 || sizes["windows_amd64/main.exe"] <= 0 || len(sizes) != 2 {
+		t.Errorf("Got the unexpected binary sizes '%v'", sizes)
+	}
+
+	results = BuildFoldersForTargets([]string{filepath.Join(".", "testdata", "no.go")}, outDir, "", targets)
+	if len(results) != 2 {
+		t.Errorf("Expected '2' build results, but got '%d'", len(results))
+	}
+	for _, result := range results {
+		if result.Err == nil {
+			t.Errorf("Got no error for target '%s', but expected one", result.Target)
+		}
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestBuildParallel(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+
+	errBuild := BuildFoldersParallel(dirs, outDir, "", 2)
+	if len(errBuild) != 0 {
+		t.Errorf("Got error '%s', but expected none", errBuild[0].Error())
+	}
+
+	binOutPath := filepath.Join(outDir, getExecutableName(filepath.Base(dirs[0]), runtime.GOOS))
+	if !PathExists(binOutPath) {
+		t.Errorf("Executable '%s', was not created", binOutPath)
+	}
+
+	noGoDir := filepath.Join(".", "testdata", "no.go")
+	errBuild = BuildFoldersParallel([]string{noGoDir, dirs[0], noGoDir}, outDir, "", 0)
+	if len(errBuild) != 2 {
+		t.Errorf("Expected '2' errors, but got '%d'", len(errBuild))
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestBuildWithOptions(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+
+	options := BuildOptions{TrimPath: true, Tags: []string{"a", "b"}, DisableCgo: true, Env: []string{"BUILD_HELPER_TEST=1"}}
+	errBuild := BuildFoldersWithOptions(dirs, outDir, options)
+	if errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+
+	binOutPath := filepath.Join(outDir, getExecutableName(filepath.Base(dirs[0]), runtime.GOOS))
+	if !PathExists(binOutPath) {
+		t.Errorf("Executable '%s', was not created", binOutPath)
+	}
+
+	errBuild = BuildFoldersWithOptions(dirs, outDir, BuildOptions{BuildMode: "not-a-build-mode"})
+	if errBuild == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetBuildArgs(t *testing.T) {
+	args := getBuildArgs("out", BuildOptions{})
+	if strings.Join(args, " ") != "build -o out -v" {
+		t.Errorf("Got the unexpected arguments '%s'", strings.Join(args, " "))
+	}
+
+	options := BuildOptions{LdFlags: "-s -w", GcFlags: "all=-N", Tags: []string{"a", "b"}, TrimPath: true, BuildMode: "pie", Mod: "vendor"}
+	args = getBuildArgs("out", options)
+	expected := "build -o out -v -trimpath -buildmode=pie -mod=vendor -tags a,b -gcflags all=-N -ldflags -s -w"
+	if strings.Join(args, " ") != expected {
+		t.Errorf("Expected the arguments '%s', but got '%s'", expected, strings.Join(args, " "))
+	}
+
+	env := getBuildEnv(BuildOptions{DisableCgo: true, Env: []string{"A=1"}})
+	if strings.Join(env, " ") != "CGO_ENABLED=0 A=1" {
+		t.Errorf("Got the unexpected environment '%s'", strings.Join(env, " "))
+	}
+}
+
+func TestFindMainPackages(t *testing.T) {
+	moduleDir := filepath.Join(".", "testdata", "testCmdProject")
+	dirs, err := FindMainPackages(moduleDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	if len(dirs) != 2 {
+		t.Errorf("Expected '2' main packages, but got '%d'", len(dirs))
+	}
+
+	if !listContains(dirs, filepath.Join(moduleDir, "cmd", "tool1")) || !listContains(dirs, filepath.Join(moduleDir, "cmd", "tool2")) {
+		t.Errorf("The main packages '%s' are not the expected ones", dirs)
+	}
+
+	dirs, err = FindMainPackages(filepath.Join(".", "testdata", "not-existing-dir"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if len(dirs) != 0 {
+		t.Errorf("Expected '0' main packages, but got '%d'", len(dirs))
+	}
+}
+
+func TestBuildMainPackages(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+
+	errBuild := BuildMainPackages(filepath.Join(".", "testdata", "testCmdProject"), outDir, BuildOptions{})
+	if errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+
+	for _, tool := range []string{"tool1", "tool2"} {
+		binOutPath := filepath.Join(outDir, getExecutableName(tool, runtime.GOOS))
+		if !PathExists(binOutPath) {
+			t.Errorf("Executable '%s', was not created", binOutPath)
+		}
+	}
+
+	cmd := exec.Command(filepath.Join(outDir, getExecutableName("tool2", runtime.GOOS)))
+	outPutBytes, err := cmd.Output()
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if strings.TrimSpace(string(outPutBytes)) != "Tool 2 result is '5'" {
+		t.Errorf("The executable does not return the expected string, it returns '%s'", strings.TrimSpace(string(outPutBytes)))
+	}
+
+	errBuild = BuildMainPackages(filepath.Join(".", "testdata", "testCmdProject"), outDir, BuildOptions{BuildMode: "not-a-build-mode"})
+	if errBuild == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetExecutableName(t *testing.T) {
+	if getExecutableName("tool", "windows") != "tool.exe" {
+		t.Errorf("Expected 'tool.exe', but got '%s'", getExecutableName("tool", "windows"))
+	}
+
+	if getExecutableName("tool", "darwin") != "tool" {
+		t.Errorf("Expected 'tool', but got '%s'", getExecutableName("tool", "darwin"))
+	}
+}
+
 func TestPathExists(t *testing.T) {
 
 	workDir, errWorkDir := os.Getwd()
@@ -273,6 +484,35 @@ func TestPathExists(t *testing.T) {
 	}
 }
 
+func TestGetFileSha256(t *testing.T) {
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	file := filepath.Join(baseDir, "hash.txt")
+	if err := os.WriteFile(file, []byte("abc"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	hash, err := GetFileSha256(file)
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	expected := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if hash != expected {
+		t.Errorf("Expected the hash '%s', but got '%s'", expected, hash)
+	}
+
+	hash, err = GetFileSha256(filepath.Join(baseDir, "not-existing-file"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if hash != "" {
+		t.Errorf("Expected '', but got '%s'", hash)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
 func TestTestExecution(t *testing.T) {
 
 	RemovePaths([]string{baseDir})
@@ -331,6 +571,147 @@ func TestTestExecutionEarlyExit(t *testing.T) {
 	}
 }
 
+func TestTestExecutionParallel(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	passingDir := filepath.Join(".", "testdata", "testProject", "main")
+	failingDir := filepath.Join(".", "testdata", "testFailingProject")
+
+	errTests := RunTestFoldersParallel([]string{passingDir, failingDir}, baseDir, 2)
+	if len(errTests) != 1 {
+		t.Errorf("Expected '1' error, but got '%d'", len(errTests))
+	}
+
+	entries, err := ReadTestLogIndex(filepath.Join(baseDir, TestLogIndexFileName))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected '2' index entries, but got '%d'", len(entries))
+	}
+
+	if entries[0].Package != passingDir || entries[0].Status != "PASS" || entries[0].LogFile != getPackageLogName(passingDir) || entries[0].Duration <= 0 {
+		t.Errorf("The index entry '%v' is not as expected", entries[0])
+	}
+	if entries[1].Package != failingDir || entries[1].Status != "FAIL" {
+		t.Errorf("The index entry '%v' is not as expected", entries[1])
+	}
+
+	for _, entry := range entries {
+		if !PathExists(filepath.Join(baseDir, entry.LogFile)) {
+			t.Errorf("Test output file '%s' does not exist", entry.LogFile)
+		}
+	}
+
+	_, err = ReadTestLogIndex(filepath.Join(baseDir, "not-existing-file"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestTestExecutionParallelWithOptions(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	failingDir := filepath.Join(".", "testdata", "testFailingProject")
+	errTests := RunTestFoldersParallelWithOptions([]string{failingDir}, baseDir, 0, TestOptions{Run: "TestSubPass", Count: 1, Short: true})
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s', but expected none", errTests[0].Error())
+	}
+
+	entries, err := ReadTestLogIndex(filepath.Join(baseDir, TestLogIndexFileName))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(entries) != 1 || entries[0].Status != "PASS" {
+		t.Fatalf("Got the unexpected index entries '%v'", entries)
+	}
+	log, errRead := os.ReadFile(filepath.Join(baseDir, entries[0].LogFile))
+	if errRead != nil {
+		t.Errorf("Got error '%s', but expected none", errRead.Error())
+	}
+	if !strings.Contains(string(log), "TestSubPass") || strings.Contains(string(log), "TestSubFail") {
+		t.Errorf("The log does not contain only the selected test:\n%s", log)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetPackageLogName(t *testing.T) {
+	colliding := [][]string{
+		{filepath.Join("..", "a", "b"), filepath.Join("a", "b")},
+		{filepath.Join("a", "b"), "a_b"},
+		{filepath.Join("..", "pkg"), "pkg"},
+	}
+	for _, paths := range colliding {
+		if getPackageLogName(paths[0]) == getPackageLogName(paths[1]) {
+			t.Errorf("Got the same log name '%s' for '%s' and '%s'", getPackageLogName(paths[0]), paths[0], paths[1])
+		}
+	}
+
+	if getPackageLogName(filepath.Join(".", "a", "b")) != getPackageLogName(filepath.Join("a", "b")) {
+		t.Errorf("Got different log names for the same package path")
+	}
+
+	prefixes := map[string]string{
+		filepath.Join(".", "a", "b"):  "a_b_",
+		filepath.Join("..", "a", "b"): "a_b_",
+		".":                           "root_",
+	}
+	for packToTest, prefix := range prefixes {
+		name := getPackageLogName(packToTest)
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".log") {
+			t.Errorf("Expected a log name like '%s<hash>.log' for '%s', but got '%s'", prefix, packToTest, name)
+		}
+	}
+}
+
+func TestTestExecutionWithOptions(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToTest(filepath.Join(".", "testdata", "testFailingProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	options := TestOptions{Run: "TestSubPass", Short: true, Count: 1, Timeout: time.Minute, Shuffle: "on", Cpu: "1,2", Env: []string{"BUILD_HELPER_TEST=1"}}
+	errTests := RunTestFoldersWithOptions(dirs, baseDir, "TestResult.log", options)
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s', but expected none", errTests[0].Error())
+	}
+
+	errTests = RunTestFoldersWithOptions(dirs, baseDir, "TestResult.log", TestOptions{Run: "TestSubFail"})
+	if len(errTests) != 1 {
+		t.Errorf("Expected '1' error, but got '%d'", len(errTests))
+	}
+
+	errCover := CoverTestFoldersWithOptions(dirs, baseDir, "TestCover.log", TestOptions{Run: "^TestSubPass$", Tags: []string{"a"}})
+	if errCover != nil {
+		t.Errorf("Got error '%s', but expected none", errCover.Error())
+	}
+
+	errCover = CoverTestFoldersWithOptions(dirs, baseDir, "TestCover.log", TestOptions{Args: []string{"-not-a-flag"}})
+	if errCover == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetTestOptionArgs(t *testing.T) {
+	args := getTestOptionArgs(TestOptions{})
+	if len(args) != 0 {
+		t.Errorf("Got the unexpected arguments '%s'", strings.Join(args, " "))
+	}
+
+	options := TestOptions{Run: "TestA", Short: true, Count: 1, Timeout: 90 * time.Second, Tags: []string{"a", "b"}, Shuffle: "on", Cpu: "1,4", Args: []string{"-failfast"}}
+	expected := "-run TestA -short -count=1 -timeout=1m30s -tags a,b -shuffle=on -cpu=1,4 -failfast"
+	if strings.Join(getTestOptionArgs(options), " ") != expected {
+		t.Errorf("Expected the arguments '%s', but got '%s'", expected, strings.Join(getTestOptionArgs(options), " "))
+	}
+}
+
 func TestTestCoverage(t *testing.T) {
 	RemovePaths([]string{baseDir})
 
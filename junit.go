@@ -0,0 +1,257 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JUnitTestSuites - The root element of a JUnit XML result file
+type JUnitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite - The JUnit XML results of one go package
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+// JUnitTestCase - The JUnit XML result of one test or subtest
+type JUnitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Skipped   *JUnitSkipped `xml:"skipped,omitempty"`
+	// The failed runs of a test that passed on a retry
+	FlakyFailures []JUnitFailure `xml:"flakyFailure,omitempty"`
+	SystemOut     string         `xml:"system-out,omitempty"`
+}
+
+// JUnitFailure - The failure of a JUnitTestCase
+type JUnitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// JUnitSkipped - Marks a JUnitTestCase as skipped
+type JUnitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+var (
+	testStartPattern   = regexp.MustCompile(`^=== (RUN|CONT|PAUSE|NAME)\s+(\S+)`)
+	testResultPattern  = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+)s\)`)
+	packageDonePattern = regexp.MustCompile(`^(ok|FAIL|\?)\s*\t(\S+)\s*(.*)$`)
+	elapsedPattern     = regexp.MustCompile(`^([0-9.]+)s`)
+)
+
+// ConvertTestLogToJUnit - Converts a given 'go test -v' output log into a junit xml result file, without any external tool
+// Logs written by RunTestFolders and CoverTestFolders, containing the output of many packages, are supported
+// - logPath: The path to the 'go test -v' output log to convert
+// - xmlResult: The junit xml result output file
+// It returns any error that may occur or nil
+func ConvertTestLogToJUnit(logPath, xmlResult string) error {
+	fmt.Println(fmt.Sprintf("Convert the test results %s to %s", logPath, xmlResult))
+	suites, errParse := ParseTestLog(logPath)
+	if errParse != nil {
+		errParse = fmt.Errorf("Error: Test result conversion failed. %w", errParse)
+		fmt.Fprintln(os.Stderr, errParse)
+		return errParse
+	}
+
+	return WriteJUnitXml(xmlResult, suites)
+}
+
+// WriteJUnitXml - Write the JUnitTestSuites to a junit xml result file
+// - xmlResult: The junit xml result output file
+// - suites: The results to write
+// It returns any error that may occur or nil
+func WriteJUnitXml(xmlResult string, suites JUnitTestSuites) error {
+	if err := EnsureDirectoryExists(filepath.Dir(xmlResult)); err != nil {
+		return err
+	}
+
+	content, errXml := xml.MarshalIndent(suites, "", "  ")
+	if errXml != nil {
+		return errXml
+	}
+
+	return os.WriteFile(xmlResult, append([]byte(xml.Header), append(content, '\n')...), 0644)
+}
+
+// ParseTestLog - Parse a 'go test -v' output log into JUnitTestSuites, one suite per package
+// - logPath: The path to the 'go test -v' output log to parse
+// It returns the JUnitTestSuites and nil in case no error occur
+// In case of error the error and empty JUnitTestSuites are returned
+func ParseTestLog(logPath string) (JUnitTestSuites, error) {
+	logFile, errOpen := os.Open(logPath)
+	if errOpen != nil {
+		return JUnitTestSuites{}, errOpen
+	}
+	defer logFile.Close()
+
+	return parseTestLog(logFile)
+}
+
+// testLogCase - A test case while parsing a 'go test -v' log
+type testLogCase struct {
+	name     string
+	status   string
+	duration string
+	output   []string
+}
+
+// parseTestLog - Parse 'go test -v' output into JUnitTestSuites, one suite per package
+func parseTestLog(reader io.Reader) (JUnitTestSuites, error) {
+	suites := JUnitTestSuites{Suites: []JUnitTestSuite{}}
+	cases := []*testLogCase{}
+	caseByName := map[string]*testLogCase{}
+	packageOutput := []string{}
+	currentTest := ""
+
+	getCase := func(name string) *testLogCase {
+		logCase, found := caseByName[name]
+		if !found {
+			logCase = &testLogCase{name: name}
+			caseByName[name] = logCase
+			cases = append(cases, logCase)
+		}
+		return logCase
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := testStartPattern.FindStringSubmatch(line); match != nil {
+			getCase(match[2])
+			currentTest = match[2]
+			continue
+		}
+
+		if match := testResultPattern.FindStringSubmatch(line); match != nil {
+			logCase := getCase(match[2])
+			logCase.status = match[1]
+			logCase.duration = match[3]
+			currentTest = match[2]
+			continue
+		}
+
+		if match := packageDonePattern.FindStringSubmatch(line); match != nil {
+			suites.Suites = append(suites.Suites, getJUnitTestSuite(match[2], match[1], match[3], cases, packageOutput))
+			cases = []*testLogCase{}
+			caseByName = map[string]*testLogCase{}
+			packageOutput = []string{}
+			currentTest = ""
+			continue
+		}
+
+		if line == "PASS" || line == "FAIL" || strings.HasPrefix(line, "coverage: ") {
+			continue
+		}
+
+		if currentTest != "" {
+			logCase := getCase(currentTest)
+			logCase.output = append(logCase.output, line)
+		} else {
+			packageOutput = append(packageOutput, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return JUnitTestSuites{}, err
+	}
+
+	if len(cases) > 0 {
+		suites.Suites = append(suites.Suites, getJUnitTestSuite("unknown", "FAIL", "", cases, packageOutput))
+	}
+
+	return suites, nil
+}
+
+// getJUnitTestSuite - Create the JUnitTestSuite of a package once its result line was found in the log
+// - packageName: The import path of the package
+// - packageStatus: The status of the package, 'ok', 'FAIL' or '?'
+// - packageInfo: The rest of the result line, like the elapsed time or '[build failed]'
+// - cases: The test cases of the package, in the order of the log
+// - packageOutput: The output of the package that does not belong to a test
+// It returns the JUnitTestSuite
+func getJUnitTestSuite(packageName, packageStatus, packageInfo string, cases []*testLogCase, packageOutput []string) JUnitTestSuite {
+	suite := JUnitTestSuite{Name: packageName, Time: "0.000", TestCases: []JUnitTestCase{}}
+	if match := elapsedPattern.FindStringSubmatch(packageInfo); match != nil {
+		suite.Time = formatJUnitTime(match[1])
+	}
+
+	for _, logCase := range cases {
+		testCase := JUnitTestCase{ClassName: packageName, Name: logCase.name, Time: formatJUnitTime(logCase.duration)}
+		output := strings.Join(logCase.output, "\n")
+		switch logCase.status {
+		case "PASS":
+			testCase.SystemOut = output
+		case "SKIP":
+			testCase.Skipped = &JUnitSkipped{Message: strings.TrimSpace(output)}
+			suite.Skipped++
+		case "FAIL":
+			testCase.Failure = &JUnitFailure{Message: getFailureMessage(logCase.output), Type: "Failure", Contents: output}
+			suite.Failures++
+		default:
+			testCase.Failure = &JUnitFailure{Message: "The test did not finish", Type: "Error", Contents: output}
+			suite.Errors++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	if packageStatus == "FAIL" && suite.Failures+suite.Errors == 0 {
+		testCase := JUnitTestCase{ClassName: packageName, Name: packageName, Time: suite.Time}
+		testCase.Failure = &JUnitFailure{Message: strings.TrimSpace(fmt.Sprintf("The package failed %s", packageInfo)), Type: "Error", Contents: strings.Join(packageOutput, "\n")}
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Errors++
+	} else if len(packageOutput) > 0 {
+		suite.SystemOut = strings.Join(packageOutput, "\n")
+	}
+	suite.Tests = len(suite.TestCases)
+
+	return suite
+}
+
+// getFailureMessage - Get the first non empty output line of a failed test, used as failure message
+func getFailureMessage(output []string) string {
+	for _, line := range output {
+		if strings.TrimSpace(line) != "" {
+			return strings.TrimSpace(line)
+		}
+	}
+
+	return "Failed"
+}
+
+// formatJUnitTime - Format a duration in seconds, like '0.01', as used by JUnit XML
+func formatJUnitTime(seconds string) string {
+	value, err := strconv.ParseFloat(seconds, 64)
+	if err != nil {
+		return "0.000"
+	}
+
+	return fmt.Sprintf("%.3f", value)
+}
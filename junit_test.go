@@ -0,0 +1,121 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTestLog(t *testing.T) {
+	suites, err := ParseTestLog(filepath.Join(".", "testdata", "testLogs", "TestResult.log"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	if len(suites.Suites) != 4 {
+		t.Fatalf("Expected '4' suites, but got '%d'", len(suites.Suites))
+	}
+
+	sample := suites.Suites[0]
+	if sample.Name != "example.com/sample" || sample.Tests != 8 || sample.Failures != 3 || sample.Skipped != 1 || sample.Errors != 0 {
+		t.Errorf("The suite '%s' has '%d' tests, '%d' failures, '%d' skipped and '%d' errors", sample.Name, sample.Tests, sample.Failures, sample.Skipped, sample.Errors)
+	}
+	if sample.Time != "0.003" {
+		t.Errorf("Expected the time '0.003', but got '%s'", sample.Time)
+	}
+
+	for _, testCase := range sample.TestCases {
+		switch testCase.Name {
+		case "TestFail":
+			if testCase.Failure == nil || testCase.Failure.Message != "s_test.go:6: bad value" || !strings.Contains(testCase.Failure.Contents, "second line") {
+				t.Errorf("The test case '%s' does not have the expected failure", testCase.Name)
+			}
+		case "TestSub/b":
+			if testCase.Failure == nil || testCase.Failure.Message != "s_test.go:10: sub failed" {
+				t.Errorf("The test case '%s' does not have the expected failure", testCase.Name)
+			}
+		case "TestSkip":
+			if testCase.Skipped == nil || testCase.Skipped.Message != "s_test.go:7: not now" {
+				t.Errorf("The test case '%s' is not skipped as expected", testCase.Name)
+			}
+		case "TestPass":
+			if testCase.Failure != nil || testCase.Skipped != nil || !strings.Contains(testCase.SystemOut, "hello") {
+				t.Errorf("The test case '%s' is not passed as expected", testCase.Name)
+			}
+		}
+	}
+
+	broken := suites.Suites[1]
+	if broken.Name != "example.com/broken" || broken.Errors != 1 || !strings.Contains(broken.TestCases[0].Failure.Contents, "syntax error") {
+		t.Errorf("The suite '%s' does not report the build failure", broken.Name)
+	}
+
+	if suites.Suites[2].Tests != 0 || suites.Suites[3].Tests != 1 || suites.Suites[3].Failures != 0 {
+		t.Errorf("The suites '%s' and '%s' are not as expected", suites.Suites[2].Name, suites.Suites[3].Name)
+	}
+
+	_, err = ParseTestLog(filepath.Join(".", "testdata", "testLogs", "not-existing-file"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
+
+func TestConvertTestLogToJUnit(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	xmlResult := filepath.Join(baseDir, "TestResult.xml")
+	err := ConvertTestLogToJUnit(filepath.Join(".", "testdata", "testLogs", "TestResult.log"), xmlResult)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	content, errRead := os.ReadFile(xmlResult)
+	if errRead != nil {
+		t.Errorf("Got error '%s', but expected none", errRead.Error())
+	}
+	suites := JUnitTestSuites{}
+	if err := xml.Unmarshal(content, &suites); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(suites.Suites) != 4 {
+		t.Errorf("Expected '4' suites, but got '%d'", len(suites.Suites))
+	}
+
+	err = ConvertTestLogToJUnit(filepath.Join(".", "testdata", "testLogs", "not-existing-file"), xmlResult)
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestConvertRunTestFoldersLog(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToTest(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	errTests := CoverTestFolders(dirs, baseDir, "TestCover.log")
+	if errTests != nil {
+		t.Errorf("Got error '%s', but expected none", errTests.Error())
+	}
+
+	suites, err := ParseTestLog(filepath.Join(".", baseDir, "TestCover.log"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(suites.Suites) != 1 || suites.Suites[0].Tests != 1 || suites.Suites[0].TestCases[0].Name != "TestAdd" {
+		t.Errorf("The suites '%v' do not contain the expected 'TestAdd' test", suites.Suites)
+	}
+
+	RemovePaths([]string{baseDir})
+}
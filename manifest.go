@@ -0,0 +1,126 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// BuildManifest - Machine-readable list of the executables created by a build
+type BuildManifest struct {
+	Artifacts []BuildManifestEntry `json:"artifacts"`
+}
+
+// BuildManifestEntry - Description of one executable created by a build
+type BuildManifestEntry struct {
+	Package         string  `json:"package"`
+	OutputPath      string  `json:"outputPath"`
+	OS              string  `json:"os"`
+	Arch            string  `json:"arch"`
+	Size            int64   `json:"size"`
+	Sha256          string  `json:"sha256"`
+	GoVersion       string  `json:"goVersion"`
+	LdFlags         string  `json:"ldFlags"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// WriteBuildManifest - Write a BuildManifest as JSON file
+// - path: The path of the JSON file to write
+// - manifest: The BuildManifest to write
+// It returns any error that may occur or nil
+func WriteBuildManifest(path string, manifest BuildManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// ReadBuildManifest - Read a BuildManifest JSON file, as written by WriteBuildManifest
+// - path: The path of the JSON file to read
+// It returns the BuildManifest and nil in case no error occur
+// In case of error the error and an empty BuildManifest is returned
+func ReadBuildManifest(path string) (BuildManifest, error) {
+	content, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return BuildManifest{}, errRead
+	}
+
+	manifest := BuildManifest{}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return BuildManifest{}, err
+	}
+
+	return manifest, nil
+}
+
+// getBuildManifestEntry - Get the BuildManifestEntry of an executable that was just build
+// - workDir: The directory the build was running in
+// - packToBuild: The package directory path that was build
+// - outPutPath: The path of the executable
+// - options: The BuildOptions of the build
+// - duration: The time the build took
+// It returns the BuildManifestEntry and nil in case no error occur
+// In case of error the error and an empty BuildManifestEntry is returned
+func getBuildManifestEntry(workDir, packToBuild, outPutPath string, options BuildOptions, duration time.Duration) (BuildManifestEntry, error) {
+	info, errStat := os.Stat(outPutPath)
+	if errStat != nil {
+		return BuildManifestEntry{}, errStat
+	}
+
+	hash, errHash := GetFileSha256(outPutPath)
+	if errHash != nil {
+		return BuildManifestEntry{}, errHash
+	}
+
+	goEnv, errGoEnv := getGoEnv(workDir, options, "GOOS", "GOARCH", "GOVERSION")
+	if errGoEnv != nil {
+		return BuildManifestEntry{}, errGoEnv
+	}
+
+	return BuildManifestEntry{
+		Package:         packToBuild,
+		OutputPath:      outPutPath,
+		OS:              goEnv[0],
+		Arch:            goEnv[1],
+		Size:            info.Size(),
+		Sha256:          hash,
+		GoVersion:       goEnv[2],
+		LdFlags:         options.LdFlags,
+		DurationSeconds: duration.Seconds(),
+	}, nil
+}
+
+// getGoEnv - Get the values of go environment variables, as seen by a build with the given options
+// - workDir: The directory the command runs in
+// - options: The BuildOptions that define the environment of the build
+// - names: The names of the variables, e.g. 'GOOS'
+// It returns the values in the order of the names and nil in case no error occur
+// In case of error the error and an empty list is returned
+func getGoEnv(workDir string, options BuildOptions, names ...string) ([]string, error) {
+	cmd := exec.Command("go", append([]string{"env"}, names...)...)
+	cmd.Dir = workDir
+	if env := getBuildEnv(options); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return []string{}, err
+	}
+
+	values := strings.Split(strings.TrimRight(string(output), "\r\n"), "\n")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+
+	return values, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBuildManifest(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+	manifestPath := filepath.Join(outDir, "manifest.json")
+
+	errBuild := BuildFoldersWithOptions(dirs, outDir, BuildOptions{LdFlags: "-s -w", ManifestPath: manifestPath})
+	if errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+
+	manifest, err := ReadBuildManifest(manifestPath)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(manifest.Artifacts) != 1 {
+		t.Fatalf("Expected '1' artifact, but got '%d'", len(manifest.Artifacts))
+	}
+
+	entry := manifest.Artifacts[0]
+	if entry.OS != runtime.GOOS || entry.Arch != runtime.GOARCH {
+		t.Errorf("Expected the target '%s/%s', but got '%s/%s'", runtime.GOOS, runtime.GOARCH, entry.OS, entry.Arch)
+	}
+	if entry.LdFlags != "-s -w" || entry.Package != dirs[0] {
+		t.Errorf("The entry '%v' does not match the build", entry)
+	}
+	hash, _ := GetFileSha256(entry.OutputPath)
+	if entry.Sha256 != hash || entry.Size <= 0 || entry.GoVersion == "" {
+		t.Errorf("The entry '%v' does not describe the executable", entry)
+	}
+
+	manifestPath = filepath.Join(outDir, "cmd-manifest.json")
+	errBuild = BuildMainPackages(filepath.Join(".", "testdata", "testCmdProject"), outDir, BuildOptions{ManifestPath: manifestPath, Env: []string{"GOOS=windows"}})
+	if errBuild != nil {
+		t.Errorf("Got error '%s', but expected none", errBuild.Error())
+	}
+	manifest, err = ReadBuildManifest(manifestPath)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(manifest.Artifacts) != 2 {
+		t.Fatalf("Expected '2' artifacts, but got '%d'", len(manifest.Artifacts))
+	}
+	if manifest.Artifacts[0].OS != "windows" {
+		t.Errorf("Expected the os 'windows', but got '%s'", manifest.Artifacts[0].OS)
+	}
+	if filepath.Base(manifest.Artifacts[0].OutputPath) != "tool1.exe" {
+		t.Errorf("Expected the executable 'tool1.exe', but got '%s'", manifest.Artifacts[0].OutputPath)
+	}
+
+	_, err = ReadBuildManifest(filepath.Join(outDir, "not-existing-file"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestBuildManifestForTargetsAndParallel(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	workDir, errWorkDir := os.Getwd()
+	if errWorkDir != nil {
+		t.Errorf("Got error '%s', but expected none", errWorkDir.Error())
+	}
+	outDir := filepath.Join(workDir, baseDir)
+	manifestPath := filepath.Join(outDir, "targets-manifest.json")
+
+	targets := []BuildTarget{{OS: "linux", Arch: "arm64"}, {OS: "windows", Arch: "amd64"}}
+	results, errManifest := BuildFoldersForTargetsWithOptions(dirs, outDir, BuildOptions{LdFlags: "-s -w", ManifestPath: manifestPath}, targets)
+	if errManifest != nil {
+		t.Errorf("Got error '%s', but expected none", errManifest.Error())
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("Got error '%s' for target '%s', but expected none", result.Err.Error(), result.Target)
+		}
+	}
+
+	manifest, err := ReadBuildManifest(manifestPath)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(manifest.Artifacts) != 2 {
+		t.Fatalf("Expected '2' artifacts, but got '%d'", len(manifest.Artifacts))
+	}
+	for i, entry := range manifest.Artifacts {
+		if entry.OS != targets[i].OS || entry.Arch != targets[i].Arch {
+			t.Errorf("Expected the target '%s', but got '%s/%s'", targets[i], entry.OS, entry.Arch)
+		}
+		if entry.OutputPath != results[i].OutputPath || entry.LdFlags != "-s -w" || entry.Package != dirs[0] {
+			t.Errorf("The entry '%v' does not match the build", entry)
+		}
+	}
+
+	manifestPath = filepath.Join(outDir, "parallel-manifest.json")
+	noGoDir := filepath.Join(".", "testdata", "no.go")
+	errBuild := BuildFoldersParallelWithOptions([]string{noGoDir, dirs[0]}, outDir, BuildOptions{ManifestPath: manifestPath, Env: []string{"GOOS=windows"}}, 2)
+	if len(errBuild) != 1 {
+		t.Errorf("Expected '1' error, but got '%d'", len(errBuild))
+	}
+	manifest, err = ReadBuildManifest(manifestPath)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(manifest.Artifacts) != 1 {
+		t.Fatalf("Expected '1' artifact, but got '%d'", len(manifest.Artifacts))
+	}
+	if manifest.Artifacts[0].OS != "windows" || filepath.Base(manifest.Artifacts[0].OutputPath) != "main.exe" {
+		t.Errorf("The entry '%v' does not match the build", manifest.Artifacts[0])
+	}
+
+	RemovePaths([]string{baseDir})
+}
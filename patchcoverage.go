@@ -0,0 +1,281 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchFileCoverage - The coverage of the added or changed lines of one file
+type PatchFileCoverage struct {
+	// The path of the file, relative to the module directory, with '/' as separator
+	FileName string
+	// The number of added or changed lines that are part of a cover profile block
+	Lines        int
+	CoveredLines int
+	Percent      float64
+	// The added or changed lines not covered by any test, sorted
+	UncoveredLines []int
+}
+
+// PatchCoverageReport - The coverage of the lines added or changed since a base git reference
+type PatchCoverageReport struct {
+	BaseRef      string
+	Lines        int
+	CoveredLines int
+	Percent      float64
+	// The files with added or changed lines that are part of a cover profile block, sorted by name
+	Files []PatchFileCoverage
+	// The changed go files, without '_test.go' files, that are not part of the cover profile, like files of packages without tests
+	UntrackedFiles []string
+}
+
+type PatchCoverageBelowThreshold struct {
+	err    string
+	report PatchCoverageReport
+}
+
+func (e *PatchCoverageBelowThreshold) Error() string { // Implement the Error Interface for the PatchCoverageBelowThreshold struct
+	return fmt.Sprintf("Error: %s", e.err)
+}
+
+// Report - Get the PatchCoverageReport that is below the threshold
+func (e *PatchCoverageBelowThreshold) Report() PatchCoverageReport {
+	return e.report
+}
+
+// NewPatchCoverageBelowThreshold - Get a new PatchCoverageBelowThreshold struct
+func NewPatchCoverageBelowThreshold(report PatchCoverageReport, minPercent float64) *PatchCoverageBelowThreshold {
+	return &PatchCoverageBelowThreshold{fmt.Sprintf("The patch coverage since \"%s\" is %.1f%%, but at least %.1f%% is required", report.BaseRef, report.Percent, minPercent), report}
+}
+
+// GetPatchCoverage - Get the coverage of the go lines added or changed since the merge base of baseRef and HEAD, including not committed changes
+// A changed line counts if it is part of a block of the cover profile, like a line in the Cobertura report of WriteCoberturaReport.
+// Changes of '_test.go' files are ignored
+// - profilePath: The path to the cover profile, like the merged profile of CoverTestFoldersWithProfile
+// - moduleDir: The root directory of the module, that contains the 'go.mod' file
+// - baseRef: The git reference to compare with, like 'origin/main'
+// It returns the PatchCoverageReport and nil in case no error occur
+// In case of error the error and an empty PatchCoverageReport is returned
+func GetPatchCoverage(profilePath, moduleDir, baseRef string) (PatchCoverageReport, error) {
+	profile, errProfile := ReadCoverProfile(profilePath)
+	if errProfile != nil {
+		return PatchCoverageReport{}, errProfile
+	}
+	modulePath, errModule := ReadModulePath(moduleDir)
+	if errModule != nil {
+		return PatchCoverageReport{}, errModule
+	}
+	changedLines, errDiff := getChangedLines(moduleDir, baseRef)
+	if errDiff != nil {
+		return PatchCoverageReport{}, errDiff
+	}
+
+	fileNames := []string{}
+	for fileName := range changedLines {
+		if strings.HasSuffix(fileName, ".go") && !strings.HasSuffix(fileName, "_test.go") {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+	sort.Strings(fileNames)
+
+	lineHits := getCoverProfileLineHits(profile)
+	report := PatchCoverageReport{BaseRef: baseRef, Files: []PatchFileCoverage{}, UntrackedFiles: []string{}}
+	for _, fileName := range fileNames {
+		hits, found := lineHits[modulePath+"/"+fileName]
+		if !found {
+			report.UntrackedFiles = append(report.UntrackedFiles, fileName)
+			continue
+		}
+
+		fileCoverage := PatchFileCoverage{FileName: fileName, UncoveredLines: []int{}}
+		for _, line := range changedLines[fileName] {
+			count, coverable := hits[line]
+			if !coverable {
+				continue
+			}
+			fileCoverage.Lines++
+			if count > 0 {
+				fileCoverage.CoveredLines++
+			} else {
+				fileCoverage.UncoveredLines = append(fileCoverage.UncoveredLines, line)
+			}
+		}
+		if fileCoverage.Lines == 0 {
+			continue
+		}
+
+		fileCoverage.Percent = getCoveragePercent(fileCoverage.CoveredLines, fileCoverage.Lines)
+		report.Lines += fileCoverage.Lines
+		report.CoveredLines += fileCoverage.CoveredLines
+		report.Files = append(report.Files, fileCoverage)
+		if len(fileCoverage.UncoveredLines) > 0 {
+			fmt.Println(fmt.Sprintf("Uncovered new lines in '%s': %s", fileName, formatLineRanges(fileCoverage.UncoveredLines)))
+		}
+	}
+	report.Percent = getCoveragePercent(report.CoveredLines, report.Lines)
+	fmt.Println(fmt.Sprintf("Patch coverage since '%s': %.1f%% of %d changed lines", baseRef, report.Percent, report.Lines))
+
+	return report, nil
+}
+
+// CheckPatchCoverageThreshold - Check the PatchCoverageReport against the minimal coverage
+// - report: The PatchCoverageReport to check
+// - minPercent: The minimal patch coverage in percent, the check is disabled if '0'
+// It returns a *PatchCoverageBelowThreshold error if the patch coverage is below minPercent, otherwise nil
+func CheckPatchCoverageThreshold(report PatchCoverageReport, minPercent float64) error {
+	if minPercent <= 0 || report.Percent >= minPercent {
+		return nil
+	}
+
+	errThreshold := NewPatchCoverageBelowThreshold(report, minPercent)
+	fmt.Fprintln(os.Stderr, errThreshold)
+	return errThreshold
+}
+
+// getChangedLines - Get the added or changed lines since the merge base of baseRef and HEAD, using 'git diff -U0'
+// - workDir: The directory this operation will run in, only changes within this directory are returned
+// - baseRef: The git reference to compare with, like 'origin/main'
+// It returns the sorted line numbers by file path, relative to workDir with '/' as separator, and nil in case no error occur
+func getChangedLines(workDir, baseRef string) (map[string][]int, error) {
+	mergeBase, errBase := getGitMergeBase(workDir, baseRef)
+	if errBase != nil {
+		return map[string][]int{}, errBase
+	}
+
+	cmd := exec.Command("git", "diff", "-U0", "--relative", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", mergeBase)
+	cmd.Dir = workDir
+	cmd.Stderr = os.Stderr
+	diff, errDiff := cmd.Output()
+	if errDiff != nil {
+		return map[string][]int{}, errDiff
+	}
+
+	return parseChangedLines(bytes.NewReader(diff))
+}
+
+// parseChangedLines - Get the added or changed lines from the output of 'git diff -U0'
+// The file headers are only read between the hunks. Inside a hunk the line counts of the hunk header are used, so an added line
+// that starts like a file header, e.g. '++ ', is not taken as one
+// - diff: The output of 'git diff' to parse
+// It returns the sorted line numbers by file path, as written in the diff without the 'b/' prefix, and nil in case no error occur
+func parseChangedLines(diff io.Reader) (map[string][]int, error) {
+	changedLines := map[string][]int{}
+	currentFile := ""
+	oldRemaining, newRemaining, newLine := 0, 0, 0
+	scanner := bufio.NewScanner(diff)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if oldRemaining > 0 || newRemaining > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				if currentFile != "" {
+					changedLines[currentFile] = append(changedLines[currentFile], newLine)
+				}
+				newLine++
+				newRemaining--
+			case strings.HasPrefix(line, "-"):
+				oldRemaining--
+			case strings.HasPrefix(line, " "):
+				newLine++
+				oldRemaining--
+				newRemaining--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			currentFile = ""
+		case strings.HasPrefix(line, "+++ "):
+			currentFile = strings.TrimPrefix(line, "+++ ")
+			if unquoted, err := strconv.Unquote(currentFile); err == nil {
+				currentFile = unquoted
+			}
+			if currentFile == "/dev/null" {
+				currentFile = ""
+			}
+			currentFile = strings.TrimPrefix(currentFile, "b/")
+		case strings.HasPrefix(line, "@@ "):
+			var errHunk error
+			oldRemaining, newLine, newRemaining, errHunk = parseHunkHeader(line)
+			if errHunk != nil {
+				return map[string][]int{}, errHunk
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return map[string][]int{}, err
+	}
+
+	return changedLines, nil
+}
+
+// parseHunkHeader - Get the number of lines of the old file, and the first line and the number of lines of the new file, from a hunk header like '@@ -10,2 +12,3 @@'
+func parseHunkHeader(header string) (int, int, int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("Error: The diff hunk header '%s' is not valid", header)
+	}
+
+	_, oldCount, errOld := parseHunkRange(header, strings.TrimPrefix(fields[1], "-"))
+	if errOld != nil {
+		return 0, 0, 0, errOld
+	}
+	start, count, errNew := parseHunkRange(header, strings.TrimPrefix(fields[2], "+"))
+	if errNew != nil {
+		return 0, 0, 0, errNew
+	}
+
+	return oldCount, start, count, nil
+}
+
+// parseHunkRange - Get the first line and the number of lines from a hunk range like '12,3' of the given hunk header
+func parseHunkRange(header, hunkRange string) (int, int, error) {
+	parts := strings.SplitN(hunkRange, ",", 2)
+	start, errStart := strconv.Atoi(parts[0])
+	if errStart != nil {
+		return 0, 0, fmt.Errorf("Error: The diff hunk header '%s' is not valid. %w", header, errStart)
+	}
+	count := 1
+	if len(parts) == 2 {
+		var errCount error
+		count, errCount = strconv.Atoi(parts[1])
+		if errCount != nil {
+			return 0, 0, fmt.Errorf("Error: The diff hunk header '%s' is not valid. %w", header, errCount)
+		}
+	}
+
+	return start, count, nil
+}
+
+// formatLineRanges - Format sorted line numbers as ranges, like '3-5, 9'
+func formatLineRanges(lines []int) string {
+	ranges := []string{}
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(ranges, ", ")
+}
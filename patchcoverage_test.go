@@ -0,0 +1,151 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetPatchCoverage(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	projectDir := filepath.Join(baseDir, "graph")
+	writeImportGraphProject(t, projectDir)
+
+	libCode := "package lib\n\nfunc Value() int {\n\treturn 1\n}\n\nfunc Double(value int) int {\n\tif value > 10 {\n\t\treturn value * 2\n\t}\n\treturn value + value\n}\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "lib", "lib.go"), []byte(libCode), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	libTest := "package lib\n\nimport \"testing\"\n\nfunc TestValue(t *testing.T) {\n\tValue()\n\tDouble(1)\n}\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "lib", "lib_test.go"), []byte(libTest), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "other", "extra.go"), []byte("package other\n\nfunc Extra() int {\n\treturn 1\n}\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	runGitCommand(t, projectDir, "add", "-A")
+	runGitCommand(t, projectDir, "commit", "-q", "-m", "Add Double")
+
+	_, errCover := CoverTestFoldersWithProfile([]string{filepath.Join(projectDir, "lib")}, baseDir, "TestCover.log", "coverage.out")
+	if errCover != nil {
+		t.Fatalf("Got error '%s' but expected none", errCover.Error())
+	}
+	profilePath := filepath.Join(baseDir, "coverage.out")
+
+	report, err := GetPatchCoverage(profilePath, projectDir, "HEAD~1")
+	if err != nil {
+		t.Fatalf("Got error '%s' but expected none", err.Error())
+	}
+	if len(report.Files) != 1 || report.Files[0].FileName != "lib/lib.go" {
+		t.Fatalf("Got the unexpected files '%v'", report.Files)
+	}
+	// The first block of 'Double' starts with the signature or the first statement, depending on the go version
+	if report.Lines-report.CoveredLines != 2 || report.CoveredLines < 2 || fmt.Sprint(report.Files[0].UncoveredLines) != "[9 10]" {
+		t.Errorf("Got the unexpected report '%v'", report)
+	}
+	if fmt.Sprint(report.UntrackedFiles) != "[other/extra.go]" {
+		t.Errorf("Got the unexpected untracked files '%v'", report.UntrackedFiles)
+	}
+
+	if err := CheckPatchCoverageThreshold(report, 40); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	err = CheckPatchCoverageThreshold(report, 80)
+	switch errType := err.(type) {
+	case *PatchCoverageBelowThreshold:
+		if errType.Report().Percent != report.Percent {
+			t.Errorf("The error '%s' has the wrong report", err.Error())
+		}
+	default:
+		t.Errorf("Got error '%v' type, but expected '*PatchCoverageBelowThreshold'", err)
+	}
+
+	report, err = GetPatchCoverage(profilePath, projectDir, "HEAD")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if report.Lines != 0 || report.Percent != 100 || CheckPatchCoverageThreshold(report, 80) != nil {
+		t.Errorf("Got the unexpected report '%v'", report)
+	}
+
+	_, err = GetPatchCoverage(profilePath, projectDir, "not-existing-ref")
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestParseHunkHeader(t *testing.T) {
+	oldCount, start, count, err := parseHunkHeader("@@ -10,2 +12,3 @@ func Value() int {")
+	if err != nil || oldCount != 2 || start != 12 || count != 3 {
+		t.Errorf("Got '%d', '%d', '%d' and '%v', but expected '2', '12', '3' and no error", oldCount, start, count, err)
+	}
+
+	oldCount, start, count, err = parseHunkHeader("@@ -10 +7 @@")
+	if err != nil || oldCount != 1 || start != 7 || count != 1 {
+		t.Errorf("Got '%d', '%d', '%d' and '%v', but expected '1', '7', '1' and no error", oldCount, start, count, err)
+	}
+
+	oldCount, start, count, err = parseHunkHeader("@@ -10,2 +9,0 @@")
+	if err != nil || oldCount != 2 || start != 9 || count != 0 {
+		t.Errorf("Got '%d', '%d', '%d' and '%v', but expected '2', '9', '0' and no error", oldCount, start, count, err)
+	}
+
+	if _, _, _, err = parseHunkHeader("@@ -10,2 12,x @@"); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if _, _, _, err = parseHunkHeader("@@ -x +12,1 @@"); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	if ranges := formatLineRanges([]int{3, 4, 5, 9, 11, 12}); ranges != "3-5, 9, 11-12" {
+		t.Errorf("Got the unexpected ranges '%s'", ranges)
+	}
+}
+
+func TestParseChangedLines(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -1,0 +2,2 @@
++++ counter
++--- value
+@@ -5 +7 @@
+-old
++new
+diff --git a/b.go b/b.go
+deleted file mode 100644
+--- a/b.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package b
+diff --git a/c.go b/c.go
+new file mode 100644
+--- /dev/null
++++ b/c.go
+@@ -0,0 +1,2 @@
++package c
++
+\ No newline at end of file
+`
+	changedLines, err := parseChangedLines(strings.NewReader(diff))
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	expected := map[string][]int{"a.go": {2, 3, 7}, "c.go": {1, 2}}
+	if fmt.Sprint(changedLines) != fmt.Sprint(expected) {
+		t.Errorf("Got the changed lines '%v', but expected '%v'", changedLines, expected)
+	}
+
+	if _, err = parseChangedLines(strings.NewReader("+++ b/a.go\n@@ -1 +x @@\n")); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
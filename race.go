@@ -0,0 +1,106 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+)
+
+// RaceDetectorMode - Tell if the tests should run with the race detector ('-race')
+type RaceDetectorMode int
+
+const (
+	// RaceDetectorAuto - Use the race detector when the target platform supports it and cgo is enabled
+	RaceDetectorAuto RaceDetectorMode = iota
+	// RaceDetectorOn - Always use the race detector, the tests fail on platforms that do not support it
+	RaceDetectorOn
+	// RaceDetectorOff - Never use the race detector
+	RaceDetectorOff
+)
+
+// IsRaceDetectorSupported - Tell if the race detector is supported for the given platform
+// The list follows 'RaceDetectorSupported' of the go tool sources. The race detector needs cgo in addition
+// - goos: The GOOS of the platform
+// - goarch: The GOARCH of the platform
+// It returns true if 'go test -race' is supported on the platform
+func IsRaceDetectorSupported(goos, goarch string) bool {
+	switch goos {
+	case "linux":
+		return goarch == "amd64" || goarch == "ppc64le" || goarch == "arm64" || goarch == "s390x" || goarch == "loong64" || goarch == "riscv64"
+	case "darwin":
+		return goarch == "amd64" || goarch == "arm64"
+	case "freebsd", "netbsd", "windows":
+		return goarch == "amd64"
+	default:
+		return false
+	}
+}
+
+// GetRaceDetectorEnabled - Tell if the tests in the given directory run with the race detector for the given options
+// In RaceDetectorAuto mode 'go env GOOS GOARCH CGO_ENABLED' is evaluated, using the environment of the options
+// - workDir: The directory the tests run in
+// - options: The TestOptions that define the race detector mode and environment of the tests
+// It returns true if '-race' should be passed to 'go test' and nil in case no error occur
+func GetRaceDetectorEnabled(workDir string, options TestOptions) (bool, error) {
+	switch options.Race {
+	case RaceDetectorOn:
+		return true, nil
+	case RaceDetectorOff:
+		return false, nil
+	}
+
+	values, err := getGoEnv(workDir, BuildOptions{Env: options.Env}, "GOOS", "GOARCH", "CGO_ENABLED")
+	if err != nil {
+		return false, err
+	}
+	if len(values) != 3 {
+		return false, fmt.Errorf("Error: Unexpected output of 'go env' in '%s'", workDir)
+	}
+
+	return values[2] == "1" && IsRaceDetectorSupported(values[0], values[1]), nil
+}
+
+// getCoverRaceTestArgs - Get the arguments for the 'go' command to measure the coverage of a package, with '-race' only for RaceDetectorOn
+// - outputFlag: The flag that defines the output of the command, like '-v'
+// - options: The TestOptions that define the race detector mode
+// It returns the argument list, starting with 'test'
+func getCoverRaceTestArgs(outputFlag string, options TestOptions) []string {
+	return getTestArgs(outputFlag, options.Race == RaceDetectorOn)
+}
+
+// hasRaceArg - Tell if the arguments of a 'go test' command contain '-race'
+func hasRaceArg(args []string) bool {
+	for _, arg := range args {
+		if arg == "-race" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRaceLogHeader - Get the line written into a test log before the output of a package, telling if the race detector is used
+func getRaceLogHeader(packToTest string, race bool) string {
+	if race {
+		return fmt.Sprintf("Race detector for package '%s': on", packToTest)
+	}
+
+	return fmt.Sprintf("Race detector for package '%s': off", packToTest)
+}
+
+// getRaceTestArgs - Get the arguments for the 'go' command to run the tests of a package, with '-race' if enabled
+// - packToTest: The package directory the tests run in
+// - outputFlag: The flag that defines the output of the command, like '-v' or '-json'
+// - options: The TestOptions that define the race detector mode and environment of the tests
+// It returns the argument list, starting with 'test', and nil in case no error occur
+func getRaceTestArgs(packToTest, outputFlag string, options TestOptions) ([]string, error) {
+	race, err := GetRaceDetectorEnabled(packToTest, options)
+	if err != nil {
+		return []string{}, fmt.Errorf("Error: Can not detect race detector support for package '%s'. %w", packToTest, err)
+	}
+
+	return getTestArgs(outputFlag, race), nil
+}
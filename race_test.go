@@ -0,0 +1,179 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestIsRaceDetectorSupported(t *testing.T) {
+	supported := []BuildTarget{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}, {OS: "linux", Arch: "s390x"},
+		{OS: "darwin", Arch: "arm64"}, {OS: "windows", Arch: "amd64"}, {OS: "freebsd", Arch: "amd64"}}
+	for _, target := range supported {
+		if !IsRaceDetectorSupported(target.OS, target.Arch) {
+			t.Errorf("Expected race detector support for '%s'", target.String())
+		}
+	}
+
+	notSupported := []BuildTarget{{OS: "linux", Arch: "386"}, {OS: "linux", Arch: "arm"}, {OS: "windows", Arch: "arm64"},
+		{OS: "freebsd", Arch: "arm64"}, {OS: "openbsd", Arch: "amd64"}, {OS: "js", Arch: "wasm"}}
+	for _, target := range notSupported {
+		if IsRaceDetectorSupported(target.OS, target.Arch) {
+			t.Errorf("Expected no race detector support for '%s'", target.String())
+		}
+	}
+}
+
+func TestGetRaceDetectorEnabled(t *testing.T) {
+	workDir := filepath.Join(".", "testdata", "testProject", "main")
+
+	race, err := GetRaceDetectorEnabled(workDir, TestOptions{Race: RaceDetectorOn, Env: []string{"CGO_ENABLED=0"}})
+	if err != nil || !race {
+		t.Errorf("Expected the race detector to be forced on")
+	}
+
+	race, err = GetRaceDetectorEnabled(workDir, TestOptions{Race: RaceDetectorOff})
+	if err != nil || race {
+		t.Errorf("Expected the race detector to be forced off")
+	}
+
+	race, err = GetRaceDetectorEnabled(workDir, TestOptions{Env: []string{"CGO_ENABLED=0"}})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if race {
+		t.Errorf("Expected no race detector without cgo")
+	}
+
+	race, err = GetRaceDetectorEnabled(workDir, TestOptions{Env: []string{"GOARCH=386"}})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if race {
+		t.Errorf("Expected no race detector for '386'")
+	}
+
+	_, err = GetRaceDetectorEnabled(filepath.Join(".", "testdata", "not-existing"), TestOptions{})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
+
+func TestRaceDetectorInResults(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	results, errTests := RunTestFoldersWithResults([]string{filepath.Join(".", "testdata", "testProject", "main")}, baseDir, "TestResult.json")
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s' but expected none", errTests[0].Error())
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected '1' result, but got '%d'", len(results))
+	}
+
+	race, _ := GetRaceDetectorEnabled(results[0].Dir, TestOptions{})
+	if results[0].RaceDetector != race {
+		t.Errorf("Expected RaceDetector to be '%t' on '%s/%s'", race, runtime.GOOS, runtime.GOARCH)
+	}
+
+	results, errTests = RunTestFoldersWithResultsAndOptions([]string{filepath.Join(".", "testdata", "testProject", "main")}, baseDir, "TestResult.json", TestOptions{Race: RaceDetectorOff})
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s' but expected none", errTests[0].Error())
+	}
+	if len(results) != 1 || results[0].RaceDetector {
+		t.Errorf("Expected RaceDetector to be 'false' with RaceDetectorOff, but got '%v'", results)
+	}
+
+	if race {
+		results, errTests = RunTestFoldersWithRetryAndOptions([]string{filepath.Join(".", "testdata", "testProject", "main")}, baseDir, "TestResult.json", 1, TestOptions{Race: RaceDetectorOn})
+		if len(errTests) != 0 {
+			t.Errorf("Got error '%s' but expected none", errTests[0].Error())
+		}
+		if len(results) != 1 || !results[0].RaceDetector {
+			t.Errorf("Expected RaceDetector to be 'true' with RaceDetectorOn, but got '%v'", results)
+		}
+	}
+
+	errRun := RunTestFoldersWithOptions([]string{filepath.Join(".", "testdata", "testProject", "main")}, baseDir, "TestResult.log", TestOptions{Race: RaceDetectorOff})
+	if len(errRun) != 0 {
+		t.Errorf("Got error '%s' but expected none", errRun[0].Error())
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRaceDetectorInLogs(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	passingDir := filepath.Join(".", "testdata", "testProject", "main")
+	race, _ := GetRaceDetectorEnabled(passingDir, TestOptions{})
+	raceMode, raceHeader := RaceDetectorOff, getRaceLogHeader(passingDir, false)
+	if race {
+		raceMode, raceHeader = RaceDetectorOn, getRaceLogHeader(passingDir, true)
+	}
+
+	errRun := RunTestFoldersWithOptions([]string{passingDir}, baseDir, "TestResult.log", TestOptions{Race: RaceDetectorOff})
+	if len(errRun) != 0 {
+		t.Errorf("Got error '%s' but expected none", errRun[0].Error())
+	}
+	log, _ := os.ReadFile(filepath.Join(baseDir, "TestResult.log"))
+	if !strings.Contains(string(log), getRaceLogHeader(passingDir, false)) {
+		t.Errorf("The log does not tell the race detector is off:\n%s", log)
+	}
+
+	if err := CoverTestFoldersWithOptions([]string{passingDir}, baseDir, "TestCover.log", TestOptions{Race: raceMode}); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	log, _ = os.ReadFile(filepath.Join(baseDir, "TestCover.log"))
+	if !strings.Contains(string(log), raceHeader) {
+		t.Errorf("The log does not contain '%s':\n%s", raceHeader, log)
+	}
+
+	errRun = RunTestFoldersParallelWithOptions([]string{passingDir}, baseDir, 1, TestOptions{Race: raceMode})
+	if len(errRun) != 0 {
+		t.Errorf("Got error '%s' but expected none", errRun[0].Error())
+	}
+	entries, errIndex := ReadTestLogIndex(filepath.Join(baseDir, TestLogIndexFileName))
+	if errIndex != nil {
+		t.Errorf("Got error '%s' but expected none", errIndex.Error())
+	}
+	if len(entries) != 1 || entries[0].RaceDetector != race {
+		t.Errorf("Expected RaceDetector to be '%t' in the index, but got '%v'", race, entries)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestReadTestLogIndexWithoutRaceDetector(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	indexPath := filepath.Join(baseDir, TestLogIndexFileName)
+	if err := os.WriteFile(indexPath, []byte("PASS\t1s\tpkg\tpkg.log\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	entries, err := ReadTestLogIndex(indexPath)
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(entries) != 1 || entries[0].Package != "pkg" || entries[0].RaceDetector {
+		t.Errorf("Got the unexpected entries '%v'", entries)
+	}
+
+	if err := os.WriteFile(indexPath, []byte("PASS\t1s\tpkg\tpkg.log\tmaybe\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if _, err := ReadTestLogIndex(indexPath); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
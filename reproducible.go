@@ -0,0 +1,106 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReproducibleBuildResult - The SHA-256 hashes of the two builds of one package
+type ReproducibleBuildResult struct {
+	Package      string
+	FirstSha256  string
+	SecondSha256 string
+}
+
+// ReproducibleBuildReport - The result of VerifyReproducibleBuilds for all packages
+type ReproducibleBuildReport struct {
+	Results   []ReproducibleBuildResult
+	Differing []string
+}
+
+// Reproducible - Tells if both builds of the package resulted in the same binary
+func (r ReproducibleBuildResult) Reproducible() bool {
+	return r.FirstSha256 == r.SecondSha256
+}
+
+// AllReproducible - Tells if the builds of all packages resulted in the same binaries
+func (r ReproducibleBuildReport) AllReproducible() bool {
+	return len(r.Differing) == 0
+}
+
+// VerifyReproducibleBuilds - Builds all given packages twice into separate temporary directories and compares the SHA-256 of the results
+// The builds use '-trimpath' and a fixed (empty) build id added to the given options. Each of the two builds gets its own empty
+// GOCACHE, so the second build is not served from the cache of the first one. ManifestPath and SkipUnchanged of the options are ignored
+// - packagesToBuild: List of the packages directory path to build. Each directory should contain a 'go.mod' file
+// - options: The BuildOptions that define the flags and environment of the build
+// It returns the ReproducibleBuildReport, listing all packages with differing binaries by their path, and nil in case no error occur
+// In case of error the error and an empty report is returned
+func VerifyReproducibleBuilds(packagesToBuild []string, options BuildOptions) (ReproducibleBuildReport, error) {
+	options.TrimPath = true
+	options.LdFlags = strings.TrimSpace(fmt.Sprintf("%s -buildid=", options.LdFlags))
+	options.ManifestPath = ""
+	options.SkipUnchanged = false
+
+	hashes := [2]map[string]string{}
+	for i := range hashes {
+		buildHashes, errBuild := buildForReproducibilityCheck(packagesToBuild, options)
+		if errBuild != nil {
+			return ReproducibleBuildReport{}, errBuild
+		}
+		hashes[i] = buildHashes
+	}
+
+	report := ReproducibleBuildReport{Results: []ReproducibleBuildResult{}, Differing: []string{}}
+	for _, packToBuild := range packagesToBuild {
+		result := ReproducibleBuildResult{Package: packToBuild, FirstSha256: hashes[0][packToBuild], SecondSha256: hashes[1][packToBuild]}
+		if !result.Reproducible() {
+			fmt.Fprintln(os.Stderr, fmt.Sprintf("Error: The build of package '%s' is not reproducible", packToBuild))
+			report.Differing = append(report.Differing, packToBuild)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// buildForReproducibilityCheck - Build all given packages into a temporary directory, using an empty temporary GOCACHE
+// Each package gets its own sub directory, so packages with the same directory name don't overwrite each other
+// - packagesToBuild: List of the packages directory path to build
+// - options: The BuildOptions that define the flags and environment of the build
+// It returns the SHA-256 of the executables by package directory path and nil in case no error occur
+func buildForReproducibilityCheck(packagesToBuild []string, options BuildOptions) (map[string]string, error) {
+	tempDir, errTemp := os.MkdirTemp("", "gobuildhelpers-reproducible-")
+	if errTemp != nil {
+		return map[string]string{}, errTemp
+	}
+	defer RemovePaths([]string{tempDir})
+
+	options.Env = append(append([]string{}, options.Env...), fmt.Sprintf("GOCACHE=%s", filepath.Join(tempDir, "cache")))
+	hashes := map[string]string{}
+	for i, packToBuild := range packagesToBuild {
+		binDir := filepath.Join(tempDir, "bin", strconv.Itoa(i))
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			return map[string]string{}, err
+		}
+
+		outPutPath := filepath.Join(binDir, getExecutableName(filepath.Base(packToBuild), getTargetOS(options)))
+		if err := buildPackage(packToBuild, outPutPath, options, os.Stdout, os.Stderr); err != nil {
+			return map[string]string{}, err
+		}
+		hash, errHash := GetFileSha256(outPutPath)
+		if errHash != nil {
+			return map[string]string{}, errHash
+		}
+		hashes[packToBuild] = hash
+	}
+
+	return hashes, nil
+}
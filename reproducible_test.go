@@ -0,0 +1,83 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyReproducibleBuilds(t *testing.T) {
+	dirs, err := FindPackagesToBuild(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	report, err := VerifyReproducibleBuilds(dirs, BuildOptions{LdFlags: "-s -w"})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	if len(report.Results) != 1 {
+		t.Errorf("Expected '1' result, but got '%d'", len(report.Results))
+	}
+
+	if !report.AllReproducible() {
+		t.Errorf("The builds of '%s' are not reproducible", report.Differing)
+	}
+
+	if report.Results[0].FirstSha256 == "" {
+		t.Errorf("Got an empty hash, but expected some content")
+	}
+
+	_, err = VerifyReproducibleBuilds([]string{filepath.Join(".", "testdata", "no.go")}, BuildOptions{})
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
+
+func TestVerifyReproducibleBuildsWithSameDirectoryName(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	dirs := []string{filepath.Join(baseDir, "a", "tool"), filepath.Join(baseDir, "b", "tool")}
+	for i, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(fmt.Sprintf("module example.com/tool%d\n\ngo 1.18\n", i)), 0644); err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(fmt.Sprintf("package main\n\nfunc main() {\n\tprintln(%d)\n}\n", i)), 0644); err != nil {
+			t.Errorf("Got error '%s', but expected none", err.Error())
+		}
+	}
+
+	report, err := VerifyReproducibleBuilds(dirs, BuildOptions{})
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(report.Results) != 2 || !report.AllReproducible() {
+		t.Fatalf("Got the unexpected report '%v'", report)
+	}
+	if report.Results[0].Package != dirs[0] || report.Results[1].Package != dirs[1] || report.Results[0].FirstSha256 == report.Results[1].FirstSha256 {
+		t.Errorf("The results '%v' are not keyed by the package path", report.Results)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestReproducibleBuildReport(t *testing.T) {
+	result := ReproducibleBuildResult{Package: "a", FirstSha256: "1", SecondSha256: "2"}
+	if result.Reproducible() {
+		t.Errorf("The result '%v' is reproducible, but should not", result)
+	}
+
+	report := ReproducibleBuildReport{Results: []ReproducibleBuildResult{result}, Differing: []string{"a"}}
+	if report.AllReproducible() {
+		t.Errorf("The report '%v' is reproducible, but should not", report)
+	}
+}
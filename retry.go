@@ -0,0 +1,186 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// RunTestFoldersWithRetry - Runs the tests like RunTestFoldersWithResults, but re-runs failed tests up to maxRetries times
+// Only the failed top level tests are re-run, using '-run' with their exact names. A test that passes on a retry
+// is marked as Flaky in the results and does not fail the package. All runs are logged into the same log file
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file, it will contain the 'go test -json' output of all runs
+// - maxRetries: The maximal number of re-runs of a failed test
+// It returns the results of all packages and any error that may occur or an empty list
+func RunTestFoldersWithRetry(packagesToTest []string, logDir, logFileName string, maxRetries int) ([]PackageTestResult, []error) {
+	return RunTestFoldersWithRetryAndOptions(packagesToTest, logDir, logFileName, maxRetries, TestOptions{})
+}
+
+// RunTestFoldersWithRetryAndOptions - Runs the tests like RunTestFoldersWithResultsAndOptions, but re-runs failed tests up to maxRetries times
+// The re-runs use the same options, the '-run' pattern of the failed tests is added after them.
+// Packages stopped by the watchdog or the context are not re-run
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file, it will contain the 'go test -json' output of all runs
+// - maxRetries: The maximal number of re-runs of a failed test
+// - options: The TestOptions that define the flags, environment, watchdog and context of the tests
+// It returns the results of all packages and any error that may occur or an empty list
+func RunTestFoldersWithRetryAndOptions(packagesToTest []string, logDir, logFileName string, maxRetries int, options TestOptions) ([]PackageTestResult, []error) {
+	results := []PackageTestResult{}
+	testErrors := []error{}
+
+	if err := EnsureDirectoryExists(logDir); err != nil {
+		return results, append(testErrors, err)
+	}
+
+	logPath := filepath.Join(logDir, logFileName)
+	logFile, errOpen := os.Create(logPath)
+	if errOpen != nil {
+		return results, append(testErrors, errOpen)
+	}
+	defer logFile.Close()
+
+	for _, packToTest := range packagesToTest {
+		fmt.Println(fmt.Sprintf("Test package '%s', logging to '%s'", packToTest, logPath))
+		args, errArgs := getRaceTestArgs(packToTest, "-json", options)
+		if errArgs != nil {
+			fmt.Fprintln(os.Stderr, errArgs)
+			testErrors = append(testErrors, errArgs)
+			continue
+		}
+		args = append(args, getTestOptionArgs(options)...)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToTest, "go", strings.Join(args, " "), logPath))
+		packageResults, errTest := runTestJson(packToTest, args, options, logFile)
+
+		var timedOut *TestTimedOut
+		for retry := 1; errTest != nil && !errors.As(errTest, &timedOut) && !isContextDone(options.Context) && retry <= maxRetries && len(packageResults) == 1; retry++ {
+			failedTests := packageResults[0].FailedTestNames()
+			if len(failedTests) == 0 {
+				break
+			}
+
+			retryArgs := append(append([]string{}, args...), "-run", getExactTestPattern(failedTests))
+			fmt.Println(fmt.Sprintf("Retry %d of %d for the failed tests of package '%s'", retry, maxRetries, packToTest))
+			fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToTest, "go", strings.Join(retryArgs, " "), logPath))
+			retryResults, errRetry := runTestJson(packToTest, retryArgs, options, logFile)
+			if len(retryResults) != 1 {
+				break
+			}
+
+			packageResults[0] = mergeRetryResult(packageResults[0], retryResults[0])
+			errTest = errRetry
+			if errTest == nil && packageResults[0].Status != TestStatusPass {
+				errTest = fmt.Errorf("The package status is '%s'", packageResults[0].Status)
+			}
+		}
+
+		results = append(results, packageResults...)
+		if errTest != nil && len(packageResults) == 1 && len(packageResults[0].FailedTestNames()) > 0 {
+			errTest = fmt.Errorf("The tests '%s' failed. %w", strings.Join(packageResults[0].FailedTestNames(), "', '"), errTest)
+		}
+		if errTest != nil {
+			errTest = fmt.Errorf("Error: Test of package '%s' failed. %w", packToTest, errTest)
+			fmt.Fprintln(os.Stderr, errTest)
+			testErrors = append(testErrors, errTest)
+		}
+	}
+
+	return results, testErrors
+}
+
+// GetJUnitTestSuites - Convert the results of RunTestFoldersWithResults or RunTestFoldersWithRetry into JUnitTestSuites
+// Flaky tests are reported as passed with a 'flakyFailure' element for each failed run
+// - results: The results of the packages
+// It returns the JUnitTestSuites, one suite per package, ready to be written by WriteJUnitXml
+func GetJUnitTestSuites(results []PackageTestResult) JUnitTestSuites {
+	suites := JUnitTestSuites{Suites: []JUnitTestSuite{}}
+	for _, packageResult := range results {
+		suite := JUnitTestSuite{Name: packageResult.Package, Time: fmt.Sprintf("%.3f", packageResult.Duration.Seconds()), TestCases: []JUnitTestCase{}}
+		if suite.Name == "" {
+			suite.Name = packageResult.Dir
+		}
+
+		for _, test := range packageResult.AllTests() {
+			testCase := JUnitTestCase{ClassName: suite.Name, Name: test.Name, Time: fmt.Sprintf("%.3f", test.Duration.Seconds())}
+			switch test.Status {
+			case TestStatusPass:
+				testCase.SystemOut = test.Output
+			case TestStatusSkip:
+				testCase.Skipped = &JUnitSkipped{Message: strings.TrimSpace(test.Output)}
+				suite.Skipped++
+			default:
+				testCase.Failure = &JUnitFailure{Message: getFailureMessage(getOutputLines(test.Output)), Type: "Failure", Contents: test.Output}
+				suite.Failures++
+			}
+			for _, failedOutput := range test.FailedOutputs {
+				testCase.FlakyFailures = append(testCase.FlakyFailures, JUnitFailure{Message: getFailureMessage(getOutputLines(failedOutput)), Type: "Failure", Contents: failedOutput})
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		if packageResult.Status == TestStatusFail && suite.Failures == 0 {
+			testCase := JUnitTestCase{ClassName: suite.Name, Name: suite.Name, Time: suite.Time}
+			testCase.Failure = &JUnitFailure{Message: "The package failed", Type: "Error", Contents: packageResult.Output}
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Errors++
+		}
+		suite.Tests = len(suite.TestCases)
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	return suites
+}
+
+// mergeRetryResult - Replace the failed tests of a package result with the tests of the retry run
+// - original: The result of the previous run
+// - retry: The result of the retry run, containing only the retried tests
+// It returns the merged result, tests that passed on the retry are marked as Flaky
+func mergeRetryResult(original, retry PackageTestResult) PackageTestResult {
+	retriedTests := map[string]TestCaseResult{}
+	for _, test := range retry.Tests {
+		retriedTests[test.Name] = test
+	}
+
+	merged := original
+	merged.Tests = []TestCaseResult{}
+	merged.Status = TestStatusPass
+	for _, test := range original.Tests {
+		retriedTest, found := retriedTests[test.Name]
+		if test.Status == TestStatusFail && found {
+			retriedTest.FailedOutputs = append(append([]string{}, test.FailedOutputs...), test.Output)
+			retriedTest.Flaky = retriedTest.Status == TestStatusPass
+			test = retriedTest
+		}
+		if test.Status == TestStatusFail {
+			merged.Status = TestStatusFail
+		}
+		merged.Tests = append(merged.Tests, test)
+	}
+
+	return merged
+}
+
+// getExactTestPattern - Get the '-run' pattern that matches exactly the given top level tests
+func getExactTestPattern(testNames []string) string {
+	quoted := []string{}
+	for _, name := range testNames {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+
+	return fmt.Sprintf("^(%s)$", strings.Join(quoted, "|"))
+}
+
+// getOutputLines - Split the output of a test into lines
+func getOutputLines(output string) []string {
+	return strings.Split(strings.TrimRight(output, "\n"), "\n")
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTestFoldersWithRetry(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	marker, errAbs := filepath.Abs(filepath.Join(baseDir, "flaky-marker"))
+	if errAbs != nil {
+		t.Errorf("Got error '%s' but expected none", errAbs.Error())
+	}
+	os.Setenv("FLAKY_TEST_MARKER", marker)
+	defer os.Unsetenv("FLAKY_TEST_MARKER")
+
+	flakyDir := filepath.Join(".", "testdata", "testFlakyProject")
+	results, errTests := RunTestFoldersWithRetry([]string{flakyDir}, baseDir, "TestResult.json", 2)
+	if len(errTests) != 1 || !strings.Contains(errTests[0].Error(), "TestBroken") {
+		t.Errorf("Expected '1' error for 'TestBroken', but got '%v'", errTests)
+	}
+	if len(results) != 1 || len(results[0].Tests) != 3 {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+
+	for _, test := range results[0].Tests {
+		switch test.Name {
+		case "TestFlaky":
+			if test.Status != TestStatusPass || !test.Flaky || len(test.FailedOutputs) != 1 {
+				t.Errorf("The test '%s' is not marked as flaky: '%v'", test.Name, test)
+			}
+		case "TestBroken":
+			if test.Status != TestStatusFail || test.Flaky || len(test.FailedOutputs) != 2 {
+				t.Errorf("The test '%s' is not failed after '2' retries: '%v'", test.Name, test)
+			}
+		case "TestStable":
+			if test.Status != TestStatusPass || test.Flaky {
+				t.Errorf("The test '%s' is not passed: '%v'", test.Name, test)
+			}
+		}
+	}
+
+	xmlResult := filepath.Join(baseDir, "TestResult.xml")
+	if err := WriteJUnitXml(xmlResult, GetJUnitTestSuites(results)); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	content, _ := os.ReadFile(xmlResult)
+	suites := JUnitTestSuites{}
+	if err := xml.Unmarshal(content, &suites); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if len(suites.Suites) != 1 || suites.Suites[0].Failures != 1 || suites.Suites[0].Tests != 3 {
+		t.Fatalf("Got the unexpected suites '%v'", suites.Suites)
+	}
+	for _, testCase := range suites.Suites[0].TestCases {
+		if testCase.Name == "TestFlaky" && (testCase.Failure != nil || len(testCase.FlakyFailures) != 1) {
+			t.Errorf("The test case '%s' is not reported as flaky", testCase.Name)
+		}
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRunTestFoldersWithRetryAndOptions(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	marker, errAbs := filepath.Abs(filepath.Join(baseDir, "flaky-marker"))
+	if errAbs != nil {
+		t.Errorf("Got error '%s' but expected none", errAbs.Error())
+	}
+
+	options := TestOptions{Run: "^(TestFlaky|TestStable)$", Count: 1, Env: []string{"FLAKY_TEST_MARKER=" + marker}}
+	results, errTests := RunTestFoldersWithRetryAndOptions([]string{filepath.Join(".", "testdata", "testFlakyProject")}, baseDir, "TestResult.json", 1, options)
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s' but expected none", errTests[0].Error())
+	}
+	if len(results) != 1 || len(results[0].Tests) != 2 || results[0].Status != TestStatusPass {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+	if results[0].Tests[0].Name != "TestFlaky" || !results[0].Tests[0].Flaky {
+		t.Errorf("The test '%s' is not marked as flaky: '%v'", results[0].Tests[0].Name, results[0].Tests[0])
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetExactTestPattern(t *testing.T) {
+	pattern := getExactTestPattern([]string{"TestA", "TestB.1"})
+	if pattern != `^(TestA|TestB\.1)$` {
+		t.Errorf("Got the unexpected pattern '%s'", pattern)
+	}
+}
+
+func TestMergeRetryResult(t *testing.T) {
+	original := PackageTestResult{Status: TestStatusFail, Tests: []TestCaseResult{
+		{Name: "TestA", Status: TestStatusFail, Output: "first"},
+		{Name: "TestB", Status: TestStatusPass},
+	}}
+	retry := PackageTestResult{Status: TestStatusPass, Tests: []TestCaseResult{{Name: "TestA", Status: TestStatusPass, Output: "second"}}}
+
+	merged := mergeRetryResult(original, retry)
+	if merged.Status != TestStatusPass || len(merged.Tests) != 2 {
+		t.Fatalf("Got the unexpected merged result '%v'", merged)
+	}
+	if !merged.Tests[0].Flaky || merged.Tests[0].Output != "second" || merged.Tests[0].FailedOutputs[0] != "first" {
+		t.Errorf("The test '%v' is not merged as expected", merged.Tests[0])
+	}
+	if original.Tests[0].Flaky {
+		t.Errorf("The original result was changed")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FindPackagesToTestForShard - Find the folders with tests like FindPackagesToTest, but only return the packages of one shard
+// The packages are balanced by their durations in the index file written by RunTestFoldersParallel, see ShardPackages
+// - sourceDir: The directory this function will start to search in recursively
+// - shardIndex: The zero based index of the shard to return
+// - shardCount: The number of shards the packages are split into
+// - indexPath: The path of the index file of a previous run, round-robin is used if it is empty or does not exist
+// It returns the list of directory paths of the shard and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindPackagesToTestForShard(sourceDir string, shardIndex, shardCount int, indexPath string) ([]string, error) {
+	packagesToTest, errFind := FindPackagesToTest(sourceDir)
+	if errFind != nil {
+		return []string{}, errFind
+	}
+
+	durations := map[string]time.Duration{}
+	if indexPath != "" && PathExists(indexPath) {
+		entries, errIndex := ReadTestLogIndex(indexPath)
+		if errIndex != nil {
+			return []string{}, errIndex
+		}
+		for _, entry := range entries {
+			durations[entry.Package] = entry.Duration
+		}
+	}
+
+	return ShardPackages(packagesToTest, shardIndex, shardCount, durations)
+}
+
+// ShardPackages - Split the packages into shardCount shards and return the packages of one shard
+// The result is stable for the same input, independent of the order of the packages. When any package has a known duration,
+// the packages are assigned longest first to the shard with the smallest total duration. Packages without a known duration
+// count with the average of the known durations. Without any known duration, the packages sorted by path are assigned round-robin
+// - packages: The list of package directories, like the ones returned by FindPackagesToTest
+// - shardIndex: The zero based index of the shard to return
+// - shardCount: The number of shards the packages are split into
+// - durations: The durations of previous runs by package directory, may be empty
+// It returns the list of package directories of the shard, sorted by path, and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func ShardPackages(packages []string, shardIndex, shardCount int, durations map[string]time.Duration) ([]string, error) {
+	if shardCount < 1 {
+		return []string{}, fmt.Errorf("Error: The shard count must be at least 1, but is '%d'", shardCount)
+	}
+	if shardIndex < 0 || shardIndex >= shardCount {
+		return []string{}, fmt.Errorf("Error: The shard index must be between 0 and %d, but is '%d'", shardCount-1, shardIndex)
+	}
+
+	sorted := []string{}
+	for _, pack := range packages {
+		if !listContains(sorted, pack) {
+			sorted = append(sorted, pack)
+		}
+	}
+	sort.Strings(sorted)
+
+	knownDurations := map[string]time.Duration{}
+	for pack, duration := range durations {
+		knownDurations[filepath.Clean(pack)] = duration
+	}
+	packageDurations := map[string]time.Duration{}
+	var total time.Duration
+	for _, pack := range sorted {
+		if duration, found := knownDurations[filepath.Clean(pack)]; found {
+			packageDurations[pack] = duration
+			total += duration
+		}
+	}
+
+	shardPackages := []string{}
+	if len(packageDurations) == 0 {
+		for i, pack := range sorted {
+			if i%shardCount == shardIndex {
+				shardPackages = append(shardPackages, pack)
+			}
+		}
+		fmt.Println(fmt.Sprintf("Shard %d of %d: %d of %d packages assigned round-robin", shardIndex+1, shardCount, len(shardPackages), len(sorted)))
+		return shardPackages, nil
+	}
+
+	average := total / time.Duration(len(packageDurations))
+	for _, pack := range sorted {
+		if _, found := packageDurations[pack]; !found {
+			packageDurations[pack] = average
+		}
+	}
+
+	byDuration := append([]string{}, sorted...)
+	sort.SliceStable(byDuration, func(i, j int) bool { return packageDurations[byDuration[i]] > packageDurations[byDuration[j]] })
+	shardDurations := make([]time.Duration, shardCount)
+	assigned := map[string]int{}
+	for _, pack := range byDuration {
+		shard := 0
+		for i := range shardDurations {
+			if shardDurations[i] < shardDurations[shard] {
+				shard = i
+			}
+		}
+		assigned[pack] = shard
+		shardDurations[shard] += packageDurations[pack]
+	}
+
+	for _, pack := range sorted {
+		if assigned[pack] == shardIndex {
+			shardPackages = append(shardPackages, pack)
+		}
+	}
+	fmt.Println(fmt.Sprintf("Shard %d of %d: %d of %d packages with an expected duration of %s", shardIndex+1, shardCount, len(shardPackages), len(sorted), shardDurations[shardIndex]))
+
+	return shardPackages, nil
+}
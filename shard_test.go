@@ -0,0 +1,102 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShardPackages(t *testing.T) {
+	packages := []string{"e", "b", "d", "a", "c"}
+
+	shards := [][]string{}
+	for i := 0; i < 2; i++ {
+		shard, err := ShardPackages(packages, i, 2, map[string]time.Duration{})
+		if err != nil {
+			t.Errorf("Got error '%s' but expected none", err.Error())
+		}
+		shards = append(shards, shard)
+	}
+	if fmt.Sprint(shards) != "[[a c e] [b d]]" {
+		t.Errorf("Got the unexpected round-robin shards '%v'", shards)
+	}
+
+	durations := map[string]time.Duration{"a": 10 * time.Second, "b": 6 * time.Second, "./c": 5 * time.Second, "x": time.Hour}
+	shards = [][]string{}
+	for i := 0; i < 2; i++ {
+		shard, err := ShardPackages(packages, i, 2, durations)
+		if err != nil {
+			t.Errorf("Got error '%s' but expected none", err.Error())
+		}
+		shards = append(shards, shard)
+	}
+	// d and e count with the average of 7s: a(10) -> 0, d(7) -> 1, e(7) -> 1, b(6) -> 0, c(5) -> 1
+	if fmt.Sprint(shards) != "[[a b] [c d e]]" {
+		t.Errorf("Got the unexpected balanced shards '%v'", shards)
+	}
+
+	shard, err := ShardPackages([]string{"b", "a"}, 2, 3, map[string]time.Duration{})
+	if err != nil || len(shard) != 0 {
+		t.Errorf("Got the unexpected shard '%v'", shard)
+	}
+
+	if _, err = ShardPackages(packages, 2, 2, durations); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if _, err = ShardPackages(packages, 0, 0, durations); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
+
+func TestFindPackagesToTestForShard(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	sourceDir := filepath.Join(".", "testdata", "testWorkspace")
+	allPackages, errFind := FindPackagesToTest(sourceDir)
+	if errFind != nil {
+		t.Errorf("Got error '%s' but expected none", errFind.Error())
+	}
+
+	shard, err := FindPackagesToTestForShard(sourceDir, 0, 1, filepath.Join(baseDir, TestLogIndexFileName))
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if fmt.Sprint(shard) != fmt.Sprint(allPackages) {
+		t.Errorf("Got the unexpected shard '%v'", shard)
+	}
+
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	entries := []TestLogIndexEntry{}
+	for i, pack := range allPackages {
+		entries = append(entries, TestLogIndexEntry{Package: pack, Status: "PASS", Duration: time.Duration(i+1) * time.Second, LogFile: getPackageLogName(pack)})
+	}
+	if err := writeTestLogIndex(filepath.Join(baseDir, TestLogIndexFileName), entries); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	total := 0
+	for i := 0; i < 2; i++ {
+		shard, err = FindPackagesToTestForShard(sourceDir, i, 2, filepath.Join(baseDir, TestLogIndexFileName))
+		if err != nil {
+			t.Errorf("Got error '%s' but expected none", err.Error())
+		}
+		total += len(shard)
+	}
+	if len(allPackages) != 3 || total != len(allPackages) {
+		t.Errorf("The shards contain '%d' packages, but expected '%d'", total, len(allPackages))
+	}
+
+	_, err = FindPackagesToTestForShard(sourceDir, 0, 1, filepath.Join(".", "testdata", "testLogs", "TestResult.log"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
@@ -0,0 +1,250 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitHubStepSummaryVariable - The environment variable GitHub Actions uses to name the job summary file
+const GitHubStepSummaryVariable = "GITHUB_STEP_SUMMARY"
+
+// MarkdownSummaryOptions - The options of GetMarkdownSummary
+type MarkdownSummaryOptions struct {
+	// The heading of the summary, 'Test results' if empty
+	Title string
+	// The number of tests listed as slowest tests, 10 if '0'
+	MaxSlowestTests int
+	// The number of output lines shown for each failed test, 20 if '0'
+	MaxOutputLines int
+}
+
+// slowTest - A top level test with the package it belongs to, used to list the slowest tests
+type slowTest struct {
+	Package string
+	Test    TestCaseResult
+}
+
+// GetMarkdownSummary - Get a Markdown summary of test results and coverage, e.g. for the GitHub Actions job summary
+// The summary contains a pass/fail table of the packages, the failed tests with the end of their output, the slowest
+// top level tests and, if a coverage report is given, the total and per package coverage
+// - results: The results of RunTestFoldersWithResults or RunTestFoldersWithRetry, may be empty
+// - coverage: The report of CoverTestFoldersWithProfile, the coverage section is skipped if nil
+// - options: The MarkdownSummaryOptions that define the size of the summary
+// It returns the Markdown text
+func GetMarkdownSummary(results []PackageTestResult, coverage *CoverageReport, options MarkdownSummaryOptions) string {
+	title := options.Title
+	if title == "" {
+		title = "Test results"
+	}
+	maxSlowestTests := options.MaxSlowestTests
+	if maxSlowestTests <= 0 {
+		maxSlowestTests = 10
+	}
+	maxOutputLines := options.MaxOutputLines
+	if maxOutputLines <= 0 {
+		maxOutputLines = 20
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "## %s\n\n", title)
+
+	if len(results) > 0 {
+		failedPackages := 0
+		summary.WriteString("| Package | Status | Passed | Failed | Skipped | Flaky | Duration |\n")
+		summary.WriteString("| --- | --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, packageResult := range results {
+			passed, failed, skipped, flaky := 0, 0, 0, 0
+			for _, test := range packageResult.AllTests() {
+				switch test.Status {
+				case TestStatusPass:
+					passed++
+				case TestStatusSkip:
+					skipped++
+				default:
+					failed++
+				}
+				if test.Flaky {
+					flaky++
+				}
+			}
+			if packageResult.Status == TestStatusFail {
+				failedPackages++
+			}
+			fmt.Fprintf(&summary, "| %s | %s | %d | %d | %d | %d | %s |\n", escapeMarkdownCell(getResultPackageName(packageResult)), getMarkdownStatus(packageResult.Status), passed, failed, skipped, flaky, formatSummaryDuration(packageResult.Duration))
+		}
+		fmt.Fprintf(&summary, "\n%d of %d packages passed\n\n", len(results)-failedPackages, len(results))
+	} else {
+		summary.WriteString("No test results\n\n")
+	}
+
+	failedTests := getMarkdownFailedTests(results, maxOutputLines)
+	if failedTests != "" {
+		summary.WriteString("### Failed tests\n\n")
+		summary.WriteString(failedTests)
+	}
+
+	slowest := getSlowestTests(results, maxSlowestTests)
+	if len(slowest) > 0 {
+		summary.WriteString("### Slowest tests\n\n")
+		summary.WriteString("| Test | Package | Duration |\n")
+		summary.WriteString("| --- | --- | ---: |\n")
+		for _, test := range slowest {
+			fmt.Fprintf(&summary, "| %s | %s | %s |\n", escapeMarkdownCell(test.Test.Name), escapeMarkdownCell(test.Package), formatSummaryDuration(test.Test.Duration))
+		}
+		summary.WriteString("\n")
+	}
+
+	if coverage != nil {
+		summary.WriteString("### Coverage\n\n")
+		fmt.Fprintf(&summary, "**Total: %.1f%%** (%d of %d statements)\n\n", coverage.Percent, coverage.CoveredStatements, coverage.Statements)
+		if len(coverage.Packages) > 0 {
+			summary.WriteString("| Package | Coverage | Statements |\n")
+			summary.WriteString("| --- | ---: | ---: |\n")
+			for _, packageCoverage := range coverage.Packages {
+				fmt.Fprintf(&summary, "| %s | %.1f%% | %d of %d |\n", escapeMarkdownCell(packageCoverage.Package), packageCoverage.Percent, packageCoverage.CoveredStatements, packageCoverage.Statements)
+			}
+			summary.WriteString("\n")
+		}
+	}
+
+	return summary.String()
+}
+
+// WriteMarkdownSummary - Append the Markdown summary of GetMarkdownSummary to a file, the file is created if it does not exist
+// - summaryPath: The path of the file to append the summary to
+// - results: The results of RunTestFoldersWithResults or RunTestFoldersWithRetry, may be empty
+// - coverage: The report of CoverTestFoldersWithProfile, the coverage section is skipped if nil
+// - options: The MarkdownSummaryOptions that define the size of the summary
+// It returns any error that may occur or nil
+func WriteMarkdownSummary(summaryPath string, results []PackageTestResult, coverage *CoverageReport, options MarkdownSummaryOptions) error {
+	summaryFile, errOpen := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer summaryFile.Close()
+
+	fmt.Println(fmt.Sprintf("Write the test summary to '%s'", summaryPath))
+	_, errWrite := summaryFile.WriteString(GetMarkdownSummary(results, coverage, options))
+
+	return errWrite
+}
+
+// WriteGitHubStepSummary - Append the Markdown summary of GetMarkdownSummary to the file named by $GITHUB_STEP_SUMMARY
+// - results: The results of RunTestFoldersWithResults or RunTestFoldersWithRetry, may be empty
+// - coverage: The report of CoverTestFoldersWithProfile, the coverage section is skipped if nil
+// - options: The MarkdownSummaryOptions that define the size of the summary
+// It returns any error that may occur or nil. If the variable is not set, e.g. outside of GitHub Actions, an error is returned
+func WriteGitHubStepSummary(results []PackageTestResult, coverage *CoverageReport, options MarkdownSummaryOptions) error {
+	summaryPath := os.Getenv(GitHubStepSummaryVariable)
+	if summaryPath == "" {
+		return fmt.Errorf("Error: The environment variable '%s' is not set", GitHubStepSummaryVariable)
+	}
+
+	return WriteMarkdownSummary(summaryPath, results, coverage, options)
+}
+
+// getMarkdownFailedTests - Get a Markdown section for each failed test, that has no failed subtests, with the end of its output
+func getMarkdownFailedTests(results []PackageTestResult, maxOutputLines int) string {
+	var section strings.Builder
+	for _, packageResult := range results {
+		for _, test := range packageResult.AllTests() {
+			if test.Status != TestStatusFail || hasFailedSubtest(test) {
+				continue
+			}
+
+			lines := []string{}
+			for _, line := range getOutputLines(test.Output) {
+				trimmed := strings.TrimSpace(line)
+				if !strings.HasPrefix(trimmed, "=== RUN") && !strings.HasPrefix(trimmed, "=== PAUSE") && !strings.HasPrefix(trimmed, "=== CONT") {
+					lines = append(lines, line)
+				}
+			}
+			if len(lines) > maxOutputLines {
+				lines = append([]string{"..."}, lines[len(lines)-maxOutputLines:]...)
+			}
+
+			excerpt := strings.Join(lines, "\n")
+			fence := "```"
+			for strings.Contains(excerpt, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&section, "**%s** in `%s`\n\n%stext\n%s\n%s\n\n", escapeMarkdownCell(test.Name), getResultPackageName(packageResult), fence, excerpt, fence)
+		}
+
+		if packageResult.Status == TestStatusFail && len(packageResult.FailedTestNames()) == 0 && strings.TrimSpace(packageResult.Output) != "" {
+			lines := getOutputLines(packageResult.Output)
+			if len(lines) > maxOutputLines {
+				lines = append([]string{"..."}, lines[len(lines)-maxOutputLines:]...)
+			}
+			fmt.Fprintf(&section, "**Package** `%s`\n\n```text\n%s\n```\n\n", getResultPackageName(packageResult), strings.Join(lines, "\n"))
+		}
+	}
+
+	return section.String()
+}
+
+// getSlowestTests - Get the top level tests of all packages with the longest duration, longest first
+func getSlowestTests(results []PackageTestResult, maxTests int) []slowTest {
+	tests := []slowTest{}
+	for _, packageResult := range results {
+		for _, test := range packageResult.Tests {
+			tests = append(tests, slowTest{Package: getResultPackageName(packageResult), Test: test})
+		}
+	}
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Test.Duration > tests[j].Test.Duration })
+	if len(tests) > maxTests {
+		tests = tests[:maxTests]
+	}
+
+	return tests
+}
+
+// hasFailedSubtest - Tell if any subtest of the test failed
+func hasFailedSubtest(test TestCaseResult) bool {
+	for _, subtest := range test.Subtests {
+		if subtest.Status == TestStatusFail {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getResultPackageName - Get the import path of the package result, or its directory if the import path is unknown
+func getResultPackageName(result PackageTestResult) string {
+	if result.Package == "" {
+		return result.Dir
+	}
+
+	return result.Package
+}
+
+// getMarkdownStatus - Get the status of a package for a Markdown table
+func getMarkdownStatus(status string) string {
+	switch status {
+	case TestStatusPass:
+		return ":white_check_mark: pass"
+	case TestStatusSkip:
+		return ":fast_forward: skip"
+	default:
+		return ":x: fail"
+	}
+}
+
+// formatSummaryDuration - Format the duration with a precision of milliseconds
+func formatSummaryDuration(duration time.Duration) string {
+	return duration.Round(time.Millisecond).String()
+}
+
+// escapeMarkdownCell - Escape the characters that would break a Markdown table cell
+func escapeMarkdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace(text)
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMarkdownSummary(t *testing.T) {
+	results := []PackageTestResult{
+		{Package: "example.com/pass", Status: TestStatusPass, Duration: 1500 * time.Millisecond, Tests: []TestCaseResult{
+			{Name: "TestSlow", Status: TestStatusPass, Duration: 1200 * time.Millisecond},
+			{Name: "TestFlaky", Status: TestStatusPass, Duration: 10 * time.Millisecond, Flaky: true},
+			{Name: "TestSkip", Status: TestStatusSkip},
+		}},
+		{Package: "example.com/fail", Status: TestStatusFail, Duration: 300 * time.Millisecond, Tests: []TestCaseResult{
+			{Name: "TestTable", Status: TestStatusFail, Duration: 200 * time.Millisecond, Output: "=== RUN   TestTable\n--- FAIL: TestTable (0.20s)\n", Subtests: []TestCaseResult{
+				{Name: "TestTable/a|b", Status: TestStatusFail, Output: "=== RUN   TestTable/a|b\n    calc_test.go:12: line 1\n    calc_test.go:13: line 2\n    calc_test.go:14: line 3\n"},
+				{Name: "TestTable/c", Status: TestStatusPass},
+			}},
+		}},
+	}
+	coverage := CoverageReport{Statements: 10, CoveredStatements: 8, Percent: 80, Packages: []PackageCoverage{{Package: "example.com/pass", Statements: 10, CoveredStatements: 8, Percent: 80}}}
+
+	summary := GetMarkdownSummary(results, &coverage, MarkdownSummaryOptions{Title: "Unit tests", MaxOutputLines: 2})
+	expectedParts := []string{
+		"## Unit tests\n",
+		"| example.com/pass | :white_check_mark: pass | 2 | 0 | 1 | 1 | 1.5s |\n",
+		"| example.com/fail | :x: fail | 1 | 2 | 0 | 0 | 300ms |\n",
+		"1 of 2 packages passed",
+		"**TestTable/a\\|b** in `example.com/fail`\n\n```text\n...\n    calc_test.go:13: line 2\n    calc_test.go:14: line 3\n```",
+		"### Slowest tests\n\n| Test | Package | Duration |\n| --- | --- | ---: |\n| TestSlow | example.com/pass | 1.2s |\n| TestTable | example.com/fail | 200ms |\n",
+		"**Total: 80.0%** (8 of 10 statements)",
+		"| example.com/pass | 80.0% | 8 of 10 |\n",
+	}
+	for _, part := range expectedParts {
+		if !strings.Contains(summary, part) {
+			t.Errorf("The summary does not contain '%s':\n%s", part, summary)
+		}
+	}
+	if strings.Contains(summary, "**TestTable**") || strings.Contains(summary, "=== RUN") {
+		t.Errorf("The summary contains unexpected failed test output:\n%s", summary)
+	}
+
+	summary = GetMarkdownSummary([]PackageTestResult{}, nil, MarkdownSummaryOptions{})
+	if !strings.HasPrefix(summary, "## Test results\n\nNo test results") || strings.Contains(summary, "### Coverage") {
+		t.Errorf("Got the unexpected summary:\n%s", summary)
+	}
+}
+
+func TestWriteGitHubStepSummary(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	summaryPath := filepath.Join(baseDir, "summary.md")
+	if err := os.WriteFile(summaryPath, []byte("# Build\n\n"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	results := []PackageTestResult{{Package: "example.com/pass", Status: TestStatusPass, Tests: []TestCaseResult{}}}
+
+	t.Setenv(GitHubStepSummaryVariable, summaryPath)
+	if err := WriteGitHubStepSummary(results, nil, MarkdownSummaryOptions{}); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	content, errRead := os.ReadFile(summaryPath)
+	if errRead != nil {
+		t.Errorf("Got error '%s' but expected none", errRead.Error())
+	}
+	if !strings.HasPrefix(string(content), "# Build\n\n## Test results\n") || !strings.Contains(string(content), "example.com/pass") {
+		t.Errorf("Got the unexpected summary file:\n%s", content)
+	}
+
+	t.Setenv(GitHubStepSummaryVariable, "")
+	if err := WriteGitHubStepSummary(results, nil, MarkdownSummaryOptions{}); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	if err := WriteMarkdownSummary(filepath.Join(baseDir, "not-existing-dir", "summary.md"), results, nil, MarkdownSummaryOptions{}); err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
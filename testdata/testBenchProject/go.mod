@@ -0,0 +1,3 @@
+module example.com/example-bench-project
+
+go 1.18
@@ -0,0 +1,11 @@
+package sum
+
+// Sum - Get the sum of all values
+func Sum(values []int) int {
+	res := 0
+	for _, value := range values {
+		res += value
+	}
+
+	return res
+}
@@ -0,0 +1,15 @@
+package sum
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if res := Sum([]int{1, 2, 3}); res != 6 {
+		t.Errorf("Result expected to be '6', but is '%d'", res)
+	}
+}
+
+func BenchmarkSum(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Sum(make([]int, 100))
+	}
+}
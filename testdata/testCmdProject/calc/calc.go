@@ -0,0 +1,5 @@
+package calc
+
+func Add(first, second int) int {
+	return first + second
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/example-cmd-project/calc"
+)
+
+func main() {
+	fmt.Println(fmt.Sprintf("Tool 1 result is '%d'", calc.Add(1, 2)))
+}
@@ -0,0 +1,3 @@
+module example.com/example-cmd-project
+
+go 1.18
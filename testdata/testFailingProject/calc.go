@@ -0,0 +1,5 @@
+package calc
+
+func Sub(first, second int) int {
+	return first - second
+}
@@ -0,0 +1,24 @@
+package calc
+
+import "testing"
+
+func TestSubPass(t *testing.T) {
+	if Sub(3, 2) != 1 {
+		t.Errorf("Result expected to be '1', but is '%d'", Sub(3, 2))
+	}
+}
+
+func TestSubFail(t *testing.T) {
+	t.Errorf("Result expected to be '2', but is '%d'", Sub(3, 2))
+}
+
+func TestSubSkip(t *testing.T) {
+	t.Skip("Skipped on purpose")
+}
+
+func TestSubCases(t *testing.T) {
+	t.Run("pass", func(t *testing.T) {})
+	t.Run("fail", func(t *testing.T) {
+		t.Errorf("Failed on purpose")
+	})
+}
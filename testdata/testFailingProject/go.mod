@@ -0,0 +1,3 @@
+module example.com/example-failing-project
+
+go 1.18
@@ -0,0 +1,28 @@
+package flaky
+
+import (
+	"os"
+	"testing"
+)
+
+// TestFlaky fails on the first run when FLAKY_TEST_MARKER names a not existing file
+func TestFlaky(t *testing.T) {
+	marker := os.Getenv("FLAKY_TEST_MARKER")
+	if marker == "" {
+		return
+	}
+
+	if _, err := os.Stat(marker); os.IsNotExist(err) {
+		os.WriteFile(marker, []byte("failed once"), 0644)
+		t.Errorf("Failed on the first run")
+	}
+}
+
+func TestStable(t *testing.T) {
+}
+
+func TestBroken(t *testing.T) {
+	if os.Getenv("FLAKY_TEST_MARKER") != "" {
+		t.Errorf("Failed on every run")
+	}
+}
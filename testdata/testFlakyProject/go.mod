@@ -0,0 +1,3 @@
+module example.com/example-flaky-project
+
+go 1.18
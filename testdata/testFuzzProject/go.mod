@@ -0,0 +1,3 @@
+module example.com/example-fuzz-project
+
+go 1.18
@@ -0,0 +1,10 @@
+package parse
+
+// Prefix - Get the first three bytes of the input
+func Prefix(input []byte) []byte {
+	if len(input) > 3 {
+		return input[:3]
+	}
+
+	return input
+}
@@ -0,0 +1,33 @@
+package parse
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefix(t *testing.T) {
+	if res := Prefix([]byte("abcd")); string(res) != "abc" {
+		t.Errorf("Result expected to be 'abc', but is '%s'", res)
+	}
+}
+
+func FuzzPrefix(f *testing.F) {
+	f.Add([]byte("abc"))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		if !bytes.HasPrefix(input, Prefix(input)) {
+			t.Errorf("The result is no prefix of '%s'", input)
+		}
+	})
+}
+
+func FuzzPrefixLength(f *testing.F) {
+	f.Add([]byte("abc"))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		if len(Prefix(input)) != len(input) {
+			t.Errorf("The result of '%s' was shortened", input)
+		}
+	})
+}
+
+func FuzzyHelper(t *testing.T) {
+}
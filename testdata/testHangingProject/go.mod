@@ -0,0 +1,3 @@
+module example.com/example-hanging-project
+
+go 1.18
@@ -0,0 +1,8 @@
+package hang
+
+import "sync"
+
+// Wait - Wait until the group is done, blocks forever if it never is
+func Wait(group *sync.WaitGroup) {
+	group.Wait()
+}
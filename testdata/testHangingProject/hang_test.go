@@ -0,0 +1,17 @@
+package hang
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestHang(t *testing.T) {
+	if os.Getenv("HANGING_TEST_ENABLED") == "" {
+		t.Skip("The test hangs only if HANGING_TEST_ENABLED is set")
+	}
+
+	group := sync.WaitGroup{}
+	group.Add(1)
+	Wait(&group)
+}
@@ -0,0 +1,5 @@
+package a
+
+func Double(value int) int {
+	return 2 * value
+}
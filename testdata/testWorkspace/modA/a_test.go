@@ -0,0 +1,9 @@
+package a
+
+import "testing"
+
+func TestDouble(t *testing.T) {
+	if Double(2) != 4 {
+		t.Errorf("Result expected to be '4', but is '%d'", Double(2))
+	}
+}
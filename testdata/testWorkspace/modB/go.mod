@@ -0,0 +1,3 @@
+module example.com/workspace-b
+
+go 1.18
@@ -0,0 +1,6 @@
+package fixture
+
+import "testing"
+
+func TestFixture(t *testing.T) {
+}
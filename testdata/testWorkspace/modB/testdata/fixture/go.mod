@@ -0,0 +1,3 @@
+module example.com/workspace-b-fixture
+
+go 1.18
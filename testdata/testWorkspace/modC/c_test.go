@@ -0,0 +1,6 @@
+package c
+
+import "testing"
+
+func TestC(t *testing.T) {
+}
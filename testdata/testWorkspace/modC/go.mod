@@ -0,0 +1,3 @@
+module example.com/workspace-c
+
+go 1.18
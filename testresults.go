@@ -0,0 +1,343 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// TestStatusPass - The status of a passed test or package
+	TestStatusPass = "pass"
+	// TestStatusFail - The status of a failed test or package
+	TestStatusFail = "fail"
+	// TestStatusSkip - The status of a skipped test or package
+	TestStatusSkip = "skip"
+)
+
+// TestCaseResult - The result of one test and its subtests
+type TestCaseResult struct {
+	// The full name of the test, like 'TestSub/case'
+	Name     string
+	Status   string
+	Duration time.Duration
+	Output   string
+	Subtests []TestCaseResult
+	// Tells if the test failed before, but passed on a retry by RunTestFoldersWithRetry
+	Flaky bool
+	// The output of the failed runs of a flaky test
+	FailedOutputs []string
+}
+
+// PackageTestResult - The result of the tests of one package
+type PackageTestResult struct {
+	// The directory the tests were executed in, may be empty if the results are read from a log
+	Dir string
+	// The import path of the package
+	Package  string
+	Status   string
+	Duration time.Duration
+	// The output of the package, that does not belong to any test
+	Output string
+	Tests  []TestCaseResult
+	// Tell if the tests ran with the race detector, always false if the results are read from a log
+	RaceDetector bool
+}
+
+// FailedTestNames - Get the names of the failed top level tests of the package
+func (r PackageTestResult) FailedTestNames() []string {
+	names := []string{}
+	for _, test := range r.Tests {
+		if test.Status == TestStatusFail {
+			names = append(names, test.Name)
+		}
+	}
+
+	return names
+}
+
+// AllTests - Get all tests and subtests of the package as flat list, parents before their subtests
+func (r PackageTestResult) AllTests() []TestCaseResult {
+	return flattenTestCaseResults(r.Tests)
+}
+
+// testEvent - One line of the 'go test -json' output, see 'go doc test2json'
+type testEvent struct {
+	Time       time.Time
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// RunTestFoldersWithResults - Runs 'go test -json [-race]' for all given packages to test
+// The race detector is used when GetRaceDetectorEnabled tells it is supported for the platform, the RaceDetector field of the results tells if it ran
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// All tests will be executed, even if a error occur in the package before, the next package's tests get executed
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file, it will contain the 'go test -json' output
+// It returns the results of all packages and any error that may occur or an empty list
+func RunTestFoldersWithResults(packagesToTest []string, logDir, logFileName string) ([]PackageTestResult, []error) {
+	return RunTestFoldersWithResultsAndOptions(packagesToTest, logDir, logFileName, TestOptions{})
+}
+
+// RunTestFoldersWithResultsAndOptions - Runs 'go test -json [-race] <options>' for all given packages to test
+// The race detector is used as defined by the Race field of the options, the RaceDetector field of the results tells if it ran
+// Any package folder in the list should contain a go package with at least one '*_test.go' file
+// All tests will be executed, even if a error occur in the package before, the next package's tests get executed
+// - packagesToTest: List of directory path that contains '*_test.go' files to run
+// - logDir: Path to the directory the log file is crated
+// - logFileName: Name of the log file, it will contain the 'go test -json' output
+// - options: The TestOptions that define the flags, environment, watchdog and context of the tests
+// It returns the results of all packages and any error that may occur or an empty list
+func RunTestFoldersWithResultsAndOptions(packagesToTest []string, logDir, logFileName string, options TestOptions) ([]PackageTestResult, []error) {
+	results := []PackageTestResult{}
+	testErrors := []error{}
+
+	if err := EnsureDirectoryExists(logDir); err != nil {
+		return results, append(testErrors, err)
+	}
+
+	logPath := filepath.Join(logDir, logFileName)
+	logFile, errOpen := os.Create(logPath)
+	if errOpen != nil {
+		return results, append(testErrors, errOpen)
+	}
+	defer logFile.Close()
+
+	for _, packToTest := range packagesToTest {
+		fmt.Println(fmt.Sprintf("Test package '%s', logging to '%s'", packToTest, logPath))
+		args, errArgs := getRaceTestArgs(packToTest, "-json", options)
+		if errArgs != nil {
+			fmt.Fprintln(os.Stderr, errArgs)
+			testErrors = append(testErrors, errArgs)
+			continue
+		}
+		args = append(args, getTestOptionArgs(options)...)
+		fmt.Println(fmt.Sprintf("Run in %s: %s %s >> %s", packToTest, "go", strings.Join(args, " "), logPath))
+
+		packageResults, errTest := runTestJson(packToTest, args, options, logFile)
+		results = append(results, packageResults...)
+		if errTest != nil {
+			errTest = fmt.Errorf("Error: Test of package '%s' failed. %w", packToTest, errTest)
+			fmt.Fprintln(os.Stderr, errTest)
+			testErrors = append(testErrors, errTest)
+		}
+	}
+
+	return results, testErrors
+}
+
+// ReadTestJsonLog - Read a 'go test -json' output log, like the one written by RunTestFoldersWithResults
+// - logPath: The path to the log to read
+// It returns the results of all packages in the log and nil in case no error occur
+// In case of error the error and an empty list is returned
+func ReadTestJsonLog(logPath string) ([]PackageTestResult, error) {
+	logFile, errOpen := os.Open(logPath)
+	if errOpen != nil {
+		return []PackageTestResult{}, errOpen
+	}
+	defer logFile.Close()
+
+	return parseTestJson(logFile)
+}
+
+// runTestJson - Runs 'go <args>' in the given package directory and parses its 'go test -json' output
+// - packToTest: The package directory to run the tests in
+// - args: The arguments of the 'go' command, containing '-json'
+// - options: The TestOptions that define the environment, watchdog and context of the tests, the flags are expected in args
+// - logWriter: The writer all output of the command is written to
+// It returns the results of the package and the error of the command or nil
+func runTestJson(packToTest string, args []string, options TestOptions, logWriter io.Writer) ([]PackageTestResult, error) {
+	var jsonOutput, errorOutput bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Dir = packToTest
+	if len(options.Env) > 0 {
+		cmd.Env = append(os.Environ(), options.Env...)
+	}
+	cmd.Stdout = io.MultiWriter(logWriter, &jsonOutput)
+	cmd.Stderr = io.MultiWriter(logWriter, &errorOutput)
+	timedOut, errTest := runCommandWithWatchdog(options.Context, cmd, options.Watchdog)
+	if timedOut {
+		errTest = NewTestTimedOut(packToTest, options.Watchdog)
+	}
+
+	packageResults, errParse := parseTestJson(&jsonOutput)
+	if errParse != nil && errTest == nil {
+		errTest = errParse
+	}
+
+	if len(packageResults) == 0 && errTest != nil {
+		packageResults = []PackageTestResult{{Status: TestStatusFail, Tests: []TestCaseResult{}}}
+	}
+	race := hasRaceArg(args)
+	for i := range packageResults {
+		packageResults[i].Dir = packToTest
+		packageResults[i].RaceDetector = race
+		if errorOutput.Len() > 0 {
+			packageResults[i].Output += errorOutput.String()
+		}
+	}
+
+	return packageResults, errTest
+}
+
+// parseTestJson - Parse the output of 'go test -json' into a result per package
+func parseTestJson(reader io.Reader) ([]PackageTestResult, error) {
+	packageOrder := []string{}
+	packages := map[string]*PackageTestResult{}
+	testOrder := map[string][]string{}
+	tests := map[string]map[string]*TestCaseResult{}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 || line[0] != '{' {
+			continue
+		}
+
+		event := testEvent{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			return []PackageTestResult{}, err
+		}
+
+		if event.Package == "" {
+			// The build events of newer go versions name the package like 'example.com/pkg [example.com/pkg.test]'
+			event.Package = strings.SplitN(event.ImportPath, " ", 2)[0]
+		}
+
+		packageResult, found := packages[event.Package]
+		if !found {
+			packageResult = &PackageTestResult{Package: event.Package, Tests: []TestCaseResult{}}
+			packages[event.Package] = packageResult
+			packageOrder = append(packageOrder, event.Package)
+			tests[event.Package] = map[string]*TestCaseResult{}
+		}
+
+		if event.Test == "" {
+			switch event.Action {
+			case "output", "build-output":
+				packageResult.Output += event.Output
+			case TestStatusPass, TestStatusFail, TestStatusSkip:
+				packageResult.Status = event.Action
+				packageResult.Duration = getElapsedDuration(event.Elapsed)
+			case "build-fail":
+				packageResult.Status = TestStatusFail
+			}
+			continue
+		}
+
+		testResult, found := tests[event.Package][event.Test]
+		if !found {
+			testResult = &TestCaseResult{Name: event.Test, Subtests: []TestCaseResult{}}
+			tests[event.Package][event.Test] = testResult
+			testOrder[event.Package] = append(testOrder[event.Package], event.Test)
+		}
+
+		switch event.Action {
+		case "output":
+			testResult.Output += event.Output
+		case TestStatusPass, TestStatusFail, TestStatusSkip:
+			testResult.Status = event.Action
+			testResult.Duration = getElapsedDuration(event.Elapsed)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return []PackageTestResult{}, err
+	}
+
+	results := []PackageTestResult{}
+	for _, packageName := range packageOrder {
+		packageResult := packages[packageName]
+		for _, testName := range testOrder[packageName] {
+			if tests[packageName][testName].Status == "" && packageResult.Status == TestStatusFail {
+				tests[packageName][testName].Status = TestStatusFail
+			}
+		}
+		packageResult.Tests = buildTestCaseTree(testOrder[packageName], tests[packageName])
+		results = append(results, *packageResult)
+	}
+
+	return results, nil
+}
+
+// buildTestCaseTree - Nest the subtests into their parent tests
+// - testOrder: The names of all tests in the order they were started
+// - tests: The tests by name
+// It returns the top level tests with their subtests
+func buildTestCaseTree(testOrder []string, tests map[string]*TestCaseResult) []TestCaseResult {
+	children := map[string][]string{}
+	topLevel := []string{}
+	for _, name := range testOrder {
+		parent := getParentTestName(name, tests)
+		if parent != "" {
+			children[parent] = append(children[parent], name)
+			continue
+		}
+		topLevel = append(topLevel, name)
+	}
+
+	var build func(name string) TestCaseResult
+	build = func(name string) TestCaseResult {
+		result := *tests[name]
+		result.Subtests = []TestCaseResult{}
+		for _, child := range children[name] {
+			result.Subtests = append(result.Subtests, build(child))
+		}
+		return result
+	}
+
+	results := []TestCaseResult{}
+	for _, name := range topLevel {
+		results = append(results, build(name))
+	}
+
+	return results
+}
+
+// getParentTestName - Get the name of the closest known parent of a subtest
+// - name: The full name of the test, like 'TestA/case/sub'
+// - tests: The known tests by name
+// It returns the name of the parent, or an empty string for top level tests
+func getParentTestName(name string, tests map[string]*TestCaseResult) string {
+	for separator := strings.LastIndex(name, "/"); separator > 0; separator = strings.LastIndex(name, "/") {
+		name = name[:separator]
+		if _, found := tests[name]; found {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// flattenTestCaseResults - Get the tests and all subtests as flat list, parents before their subtests
+func flattenTestCaseResults(tests []TestCaseResult) []TestCaseResult {
+	flat := []TestCaseResult{}
+	for _, test := range tests {
+		flat = append(flat, test)
+		flat = append(flat, flattenTestCaseResults(test.Subtests)...)
+	}
+
+	return flat
+}
+
+// getElapsedDuration - Convert the elapsed seconds of a test event into a duration
+func getElapsedDuration(elapsed float64) time.Duration {
+	return time.Duration(elapsed * float64(time.Second))
+}
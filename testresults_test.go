@@ -0,0 +1,123 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTestFoldersWithResults(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	passingDir := filepath.Join(".", "testdata", "testProject", "main")
+	failingDir := filepath.Join(".", "testdata", "testFailingProject")
+	noGoDir := filepath.Join(".", "testdata", "no.go")
+
+	results, errTests := RunTestFoldersWithResults([]string{passingDir, failingDir, noGoDir}, baseDir, "TestResult.json")
+	if len(errTests) != 2 {
+		t.Errorf("Expected '2' errors, but got '%d'", len(errTests))
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected '3' package results, but got '%d'", len(results))
+	}
+
+	passing := results[0]
+	if passing.Dir != passingDir || passing.Package != "example.com/example-project" || passing.Status != TestStatusPass {
+		t.Errorf("The result of '%s' is not as expected: '%s' '%s'", passing.Dir, passing.Package, passing.Status)
+	}
+	if len(passing.Tests) != 1 || passing.Tests[0].Name != "TestAdd" || passing.Tests[0].Status != TestStatusPass {
+		t.Errorf("The tests of '%s' are not as expected: '%v'", passing.Dir, passing.Tests)
+	}
+
+	failing := results[1]
+	if failing.Status != TestStatusFail || len(failing.Tests) != 4 {
+		t.Errorf("The result of '%s' is not as expected: '%s' with '%d' tests", failing.Dir, failing.Status, len(failing.Tests))
+	}
+	if strings.Join(failing.FailedTestNames(), ",") != "TestSubFail,TestSubCases" {
+		t.Errorf("Got the unexpected failed tests '%s'", failing.FailedTestNames())
+	}
+	if len(failing.AllTests()) != 6 {
+		t.Errorf("Expected '6' tests and subtests, but got '%d'", len(failing.AllTests()))
+	}
+	for _, test := range failing.AllTests() {
+		switch test.Name {
+		case "TestSubSkip":
+			if test.Status != TestStatusSkip || !strings.Contains(test.Output, "Skipped on purpose") {
+				t.Errorf("The test '%s' is not as expected: '%s'", test.Name, test.Status)
+			}
+		case "TestSubCases":
+			if len(test.Subtests) != 2 || test.Subtests[1].Name != "TestSubCases/fail" || test.Subtests[1].Status != TestStatusFail {
+				t.Errorf("The subtests of '%s' are not as expected: '%v'", test.Name, test.Subtests)
+			}
+		case "TestSubFail":
+			if !strings.Contains(test.Output, "Result expected to be '2', but is '1'") {
+				t.Errorf("The output of '%s' is not as expected: '%s'", test.Name, test.Output)
+			}
+		}
+	}
+
+	if results[2].Dir != noGoDir || results[2].Status != TestStatusFail || results[2].Output == "" {
+		t.Errorf("The result of '%s' is not as expected: '%s'", results[2].Dir, results[2].Status)
+	}
+
+	logResults, errRead := ReadTestJsonLog(filepath.Join(baseDir, "TestResult.json"))
+	if errRead != nil {
+		t.Errorf("Got error '%s', but expected none", errRead.Error())
+	}
+	if len(logResults) < 2 || len(logResults[1].AllTests()) != 6 {
+		t.Errorf("Expected at least '2' package results from the log, but got '%d'", len(logResults))
+	}
+
+	_, errRead = ReadTestJsonLog(filepath.Join(baseDir, "not-existing-file"))
+	if errRead == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRunTestFoldersWithResultsAndOptions(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	failingDir := filepath.Join(".", "testdata", "testFailingProject")
+	results, errTests := RunTestFoldersWithResultsAndOptions([]string{failingDir}, baseDir, "TestResult.json", TestOptions{Run: "TestSubPass|TestSubSkip", Short: true})
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s' but expected none", errTests[0].Error())
+	}
+	if len(results) != 1 || results[0].Status != TestStatusPass || len(results[0].Tests) != 2 {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestParseTestJson(t *testing.T) {
+	events := `{"Action":"run","Package":"a","Test":"TestA"}
+{"Action":"run","Package":"a","Test":"TestA/x/y"}
+{"Action":"output","Package":"a","Test":"TestA/x/y","Output":"out\n"}
+{"Action":"pass","Package":"a","Test":"TestA/x/y","Elapsed":0.5}
+{"Action":"run","Package":"a","Test":"TestB"}
+{"Action":"output","Package":"a","Output":"panic: boom\n"}
+{"Action":"fail","Package":"a","Elapsed":1.5}`
+
+	results, err := parseTestJson(strings.NewReader(events))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(results) != 1 || results[0].Duration.Seconds() != 1.5 || results[0].Output != "panic: boom\n" {
+		t.Fatalf("Got the unexpected results '%v'", results)
+	}
+	if len(results[0].Tests) != 2 || results[0].Tests[1].Status != TestStatusFail {
+		t.Errorf("Got the unexpected tests '%v'", results[0].Tests)
+	}
+
+	_, err = parseTestJson(strings.NewReader(`{"Action":`))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
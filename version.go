@@ -0,0 +1,128 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionInfo - The version of a build, calculated from a version master file and the git history
+type VersionInfo struct {
+	Major     int
+	Minor     int
+	Patch     int
+	GitHeight int
+	GitHash   string
+	BuildDate string
+}
+
+// VersionVariables - The fully qualified names of go string variables, e.g. 'main.version', set by the version ldflags
+// Each list may be empty, the corresponding value will not be set then
+type VersionVariables struct {
+	Version   []string
+	GitHash   []string
+	BuildDate []string
+}
+
+// Version - Get the version string in the form 'major.minor.(patch+height)'
+func (v VersionInfo) Version() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch+v.GitHeight)
+}
+
+// ReadVersionMaster - Read the 'major.minor.patch' version from a version master file like 'VersionMaster.txt'
+// - versionFile: The path to the version master file
+// It returns a VersionInfo with Major, Minor and Patch set and nil in case no error occur
+// In case of error the error and an empty VersionInfo is returned
+func ReadVersionMaster(versionFile string) (VersionInfo, error) {
+	byteContent, err := os.ReadFile(versionFile)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+
+	versionStr := strings.TrimSpace(string(byteContent))
+	parts := strings.Split(versionStr, ".")
+	if len(parts) != 3 {
+		return VersionInfo{}, fmt.Errorf("Error: The version '%s' in '%s' is not in the form 'major.minor.patch'", versionStr, versionFile)
+	}
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		number, errCon := strconv.Atoi(part)
+		if errCon != nil || number < 0 {
+			return VersionInfo{}, fmt.Errorf("Error: The version '%s' in '%s' is not in the form 'major.minor.patch'", versionStr, versionFile)
+		}
+		numbers[i] = number
+	}
+
+	return VersionInfo{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// GetVersionInfo - Get the full version of the build from the version master file, the git height, the git hash and the current time
+// - versionFile: The relative path (to workDir) of the version master file
+// - workDir: The directory this operation will run in. Usually the repository root directory
+// It returns the VersionInfo and nil in case no error occur
+// In case of error the error and an empty VersionInfo is returned
+func GetVersionInfo(versionFile, workDir string) (VersionInfo, error) {
+	info, errRead := ReadVersionMaster(filepath.Join(workDir, versionFile))
+	if errRead != nil {
+		return VersionInfo{}, errRead
+	}
+
+	height, errHeight := GetGitHeight(versionFile, workDir)
+	if errHeight != nil {
+		return VersionInfo{}, errHeight
+	}
+
+	hash, errHash := GetGitHash(workDir)
+	if errHash != nil {
+		return VersionInfo{}, errHash
+	}
+
+	info.GitHeight = height
+	info.GitHash = hash
+	info.BuildDate = time.Now().UTC().Format(time.RFC3339)
+
+	return info, nil
+}
+
+// GetVersionLdFlags - Get the '-X name=value' ldflags that set the given variables to the values of the VersionInfo
+// - info: The VersionInfo to stamp into the executable
+// - variables: The names of the variables to set
+// It returns the ldflags string, ready to be used with BuildFolders
+func GetVersionLdFlags(info VersionInfo, variables VersionVariables) string {
+	flags := []string{}
+	for _, name := range variables.Version {
+		flags = append(flags, fmt.Sprintf("-X %s=%s", name, info.Version()))
+	}
+	for _, name := range variables.GitHash {
+		flags = append(flags, fmt.Sprintf("-X %s=%s", name, info.GitHash))
+	}
+	for _, name := range variables.BuildDate {
+		flags = append(flags, fmt.Sprintf("-X %s=%s", name, info.BuildDate))
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// GetVersionLdFlagsFromGit - Get the '-X name=value' ldflags for the version calculated by GetVersionInfo
+// - versionFile: The relative path (to workDir) of the version master file
+// - workDir: The directory this operation will run in. Usually the repository root directory
+// - variables: The names of the variables to set
+// It returns the ldflags string and nil in case no error occur
+// In case of error the error and an empty string is returned
+func GetVersionLdFlagsFromGit(versionFile, workDir string, variables VersionVariables) (string, error) {
+	info, err := GetVersionInfo(versionFile, workDir)
+	if err != nil {
+		return "", err
+	}
+
+	return GetVersionLdFlags(info, variables), nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadVersionMaster(t *testing.T) {
+	info, err := ReadVersionMaster("VersionMaster.txt")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	if info.Version() == "0.0.0" {
+		t.Errorf("Got the version '%s', but expected some content", info.Version())
+	}
+
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	invalidFile := filepath.Join(baseDir, "InvalidVersion.txt")
+	if err := os.WriteFile(invalidFile, []byte("1.a.3"), 0644); err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	_, err = ReadVersionMaster(invalidFile)
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	_, err = ReadVersionMaster(filepath.Join(baseDir, "not_existing_file"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestGetVersionInfo(t *testing.T) {
+	info, err := GetVersionInfo("VersionMaster.txt", ".")
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+
+	if info.GitHash == "" || info.BuildDate == "" {
+		t.Errorf("The hash '%s' or the build date '%s' is empty", info.GitHash, info.BuildDate)
+	}
+
+	master, _ := ReadVersionMaster("VersionMaster.txt")
+	if info.Patch != master.Patch || info.GitHeight < 0 {
+		t.Errorf("The version '%s' does not match the version master '%s'", info.Version(), master.Version())
+	}
+
+	_, err = GetVersionInfo("not_existing_file", ".")
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+}
+
+func TestGetVersionLdFlags(t *testing.T) {
+	info := VersionInfo{Major: 1, Minor: 2, Patch: 3, GitHeight: 4, GitHash: "abc", BuildDate: "2022-01-01T00:00:00Z"}
+	if info.Version() != "1.2.7" {
+		t.Errorf("Expected the version '1.2.7', but got '%s'", info.Version())
+	}
+
+	variables := VersionVariables{Version: []string{"main.version", "pkg.Version"}, GitHash: []string{"main.hash"}}
+	flags := GetVersionLdFlags(info, variables)
+	expected := "-X main.version=1.2.7 -X pkg.Version=1.2.7 -X main.hash=abc"
+	if flags != expected {
+		t.Errorf("Expected the ldflags '%s', but got '%s'", expected, flags)
+	}
+
+	flags, err := GetVersionLdFlagsFromGit("VersionMaster.txt", ".", VersionVariables{BuildDate: []string{"main.date"}})
+	if err != nil {
+		t.Errorf("Got error '%s' but expected none", err.Error())
+	}
+	if !strings.HasPrefix(flags, "-X main.date=") {
+		t.Errorf("The ldflags '%s' do not set 'main.date'", flags)
+	}
+}
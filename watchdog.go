@@ -0,0 +1,110 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// watchdogGracePeriod - The time a test process gets to write its goroutine dump before it is killed
+const watchdogGracePeriod = 5 * time.Second
+
+// TestTimedOut - Error returned when the tests of a package did not finish in the watchdog timeout
+type TestTimedOut struct {
+	err     string
+	pack    string
+	timeout time.Duration
+}
+
+func (e *TestTimedOut) Error() string { // Implement the Error Interface for the TestTimedOut struct
+	return fmt.Sprintf("Error: %s", e.err)
+}
+
+// Package - Get the package directory that timed out
+func (e *TestTimedOut) Package() string {
+	return e.pack
+}
+
+// Timeout - Get the watchdog timeout that was exceeded
+func (e *TestTimedOut) Timeout() time.Duration {
+	return e.timeout
+}
+
+// NewTestTimedOut - Get a new TestTimedOut struct
+func NewTestTimedOut(pack string, timeout time.Duration) *TestTimedOut {
+	return &TestTimedOut{fmt.Sprintf("The tests of package \"%s\" did not finish in %s", pack, timeout), pack, timeout}
+}
+
+// runCommandWithWatchdog - Run the command and stop it when it does not finish in the given timeout or the context is done
+// When the timeout is exceeded, the process gets a SIGQUIT, so go test binaries write a goroutine dump into the
+// output. If it is still running after the watchdogGracePeriod, the process and its children are killed.
+// On windows there is no SIGQUIT, so the process is killed without a dump.
+// The command runs in its own process group, so it does not get the interrupt of the terminal. When the context is done,
+// e.g. because the caller handled an interrupt, the process and its children are killed without a dump
+// - ctx: The context that stops the command when it is done, may be nil
+// - cmd: The command to run, not yet started
+// - timeout: The watchdog timeout, the command runs without watchdog if it is '0'
+// It returns true if the timeout was exceeded and the error of the command or nil. If the context is done, an error wrapping the context error is returned
+func runCommandWithWatchdog(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 && ctx.Done() == nil {
+		return false, cmd.Run()
+	}
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("Error: The command was cancelled. %w", err)
+	}
+
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeoutChannel <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChannel = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		return false, fmt.Errorf("Error: The command was cancelled. %w", ctx.Err())
+	case <-timeoutChannel:
+	}
+
+	if err := signalProcessDump(cmd); err == nil {
+		select {
+		case <-done:
+			killProcessGroup(cmd)
+			return true, nil
+		case <-ctx.Done():
+		case <-time.After(watchdogGracePeriod):
+		}
+	}
+
+	killProcessGroup(cmd)
+	<-done
+	return true, nil
+}
+
+// isContextDone - Tell if the context is not nil and done
+func isContextDone(ctx context.Context) bool {
+	return ctx != nil && ctx.Err() != nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTestFoldersWithWatchdog(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	hangingDir := filepath.Join(".", "testdata", "testHangingProject")
+	passingDir := filepath.Join(".", "testdata", "testProject", "main")
+	options := TestOptions{Watchdog: 3 * time.Second, Env: []string{"HANGING_TEST_ENABLED=1"}}
+	start := time.Now()
+	errTests := RunTestFoldersWithOptions([]string{hangingDir, passingDir}, baseDir, "TestResult.log", options)
+	if time.Since(start) > time.Minute {
+		t.Errorf("The watchdog did not stop the tests in time")
+	}
+	if len(errTests) != 1 {
+		t.Fatalf("Expected '1' error, but got '%d'", len(errTests))
+	}
+
+	var timedOut *TestTimedOut
+	if !errors.As(errTests[0], &timedOut) {
+		t.Fatalf("Expected a TestTimedOut error, but got '%s'", errTests[0].Error())
+	}
+	if timedOut.Package() != hangingDir || timedOut.Timeout() != options.Watchdog {
+		t.Errorf("Got the unexpected error '%s'", timedOut.Error())
+	}
+
+	log, errRead := os.ReadFile(filepath.Join(baseDir, "TestResult.log"))
+	if errRead != nil {
+		t.Errorf("Got error '%s' but expected none", errRead.Error())
+	}
+	if runtime.GOOS != "windows" && !strings.Contains(string(log), "SIGQUIT") {
+		t.Errorf("Expected a goroutine dump in the log")
+	}
+	if !strings.Contains(string(log), "example.com/example-project") {
+		t.Errorf("Expected the tests after the timed out package to run")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRunTestFoldersWithWatchdogNotExceeded(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	options := TestOptions{Watchdog: time.Minute}
+	errTests := RunTestFoldersWithOptions([]string{filepath.Join(".", "testdata", "testHangingProject")}, baseDir, "TestResult.log", options)
+	if len(errTests) != 0 {
+		t.Errorf("Got error '%s' but expected none", errTests[0].Error())
+	}
+
+	errCover := CoverTestFoldersWithOptions([]string{filepath.Join(".", "testdata", "testFailingProject")}, baseDir, "TestCover.log", TestOptions{Watchdog: time.Minute})
+	if errCover == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRunTestFoldersWithResultsAndWatchdog(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	hangingDir := filepath.Join(".", "testdata", "testHangingProject")
+	options := TestOptions{Watchdog: 3 * time.Second, Env: []string{"HANGING_TEST_ENABLED=1"}}
+	results, errTests := RunTestFoldersWithResultsAndOptions([]string{hangingDir}, baseDir, "TestResult.json", options)
+	if len(errTests) != 1 {
+		t.Fatalf("Expected '1' error, but got '%d'", len(errTests))
+	}
+	var timedOut *TestTimedOut
+	if !errors.As(errTests[0], &timedOut) {
+		t.Errorf("Expected a TestTimedOut error, but got '%s'", errTests[0].Error())
+	}
+	if len(results) != 1 || results[0].Status != TestStatusFail {
+		t.Errorf("Got the unexpected results '%v'", results)
+	}
+
+	_, errCover := CoverTestFoldersWithProfileAndOptions([]string{hangingDir}, baseDir, "TestCover.log", "coverage.out", options)
+	if !errors.As(errCover, &timedOut) {
+		t.Errorf("Expected a TestTimedOut error, but got '%v'", errCover)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRunTestFoldersCancelled(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(2 * time.Second)
+		cancel()
+	}()
+	hangingDir := filepath.Join(".", "testdata", "testHangingProject")
+	options := TestOptions{Context: ctx, Env: []string{"HANGING_TEST_ENABLED=1"}}
+	start := time.Now()
+	errTests := RunTestFoldersWithOptions([]string{hangingDir, hangingDir}, baseDir, "TestResult.log", options)
+	if time.Since(start) > 30*time.Second {
+		t.Errorf("The cancellation did not stop the tests in time")
+	}
+	if len(errTests) != 2 {
+		t.Fatalf("Expected '2' errors, but got '%d'", len(errTests))
+	}
+	for _, err := range errTests {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected a cancelled error, but got '%s'", err.Error())
+		}
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestRunTestFoldersParallelWithWatchdog(t *testing.T) {
+	RemovePaths([]string{baseDir})
+
+	hangingDir := filepath.Join(".", "testdata", "testHangingProject")
+	passingDir := filepath.Join(".", "testdata", "testProject", "main")
+	options := TestOptions{Watchdog: 3 * time.Second, Env: []string{"HANGING_TEST_ENABLED=1"}}
+	errTests := RunTestFoldersParallelWithOptions([]string{hangingDir, passingDir}, baseDir, 2, options)
+	if len(errTests) != 1 {
+		t.Fatalf("Expected '1' error, but got '%d'", len(errTests))
+	}
+	var timedOut *TestTimedOut
+	if !errors.As(errTests[0], &timedOut) || timedOut.Package() != hangingDir {
+		t.Errorf("Expected a TestTimedOut error for '%s', but got '%s'", hangingDir, errTests[0].Error())
+	}
+
+	RemovePaths([]string{baseDir})
+}
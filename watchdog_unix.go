@@ -0,0 +1,29 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package gobuildhelpers
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup - Start the command in its own process group, so the test binary started by 'go test' gets the signals too
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessDump - Send SIGQUIT to the process group of the command, so go programs write a goroutine dump
+func signalProcessDump(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGQUIT)
+}
+
+// killProcessGroup - Kill the process group of the command
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
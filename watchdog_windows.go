@@ -0,0 +1,32 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+//go:build windows
+// +build windows
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup - Start the command in a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcessDump - There is no SIGQUIT on windows, so no goroutine dump can be requested
+func signalProcessDump(cmd *exec.Cmd) error {
+	return syscall.EWINDOWS
+}
+
+// killProcessGroup - Kill the process of the command and all its children using 'taskkill'
+func killProcessGroup(cmd *exec.Cmd) {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", fmt.Sprintf("%d", cmd.Process.Pid)).Run(); err != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FindWorkspaceModules - Read the 'use' directives of the 'go.work' file in the given directory
+// - workspaceDir: The directory that contains the 'go.work' file. Usually the repository root directory
+// Relative 'use' paths are joined with workspaceDir, absolute paths are returned as they are
+// It returns the sorted list of module directory paths and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindWorkspaceModules(workspaceDir string) ([]string, error) {
+	byteContent, errRead := os.ReadFile(filepath.Join(workspaceDir, "go.work"))
+	if errRead != nil {
+		return []string{}, errRead
+	}
+
+	modules := []string{}
+	inUseBlock := false
+	for i, line := range strings.Split(string(byteContent), "\n") {
+		if commentStart := strings.Index(line, "//"); commentStart >= 0 {
+			line = line[:commentStart]
+		}
+		line = strings.TrimSpace(line)
+
+		usePath := ""
+		switch {
+		case inUseBlock && line == ")":
+			inUseBlock = false
+		case inUseBlock:
+			usePath = line
+		case line == "use (" || line == "use(":
+			inUseBlock = true
+		case strings.HasPrefix(line, "use "):
+			usePath = strings.TrimSpace(strings.TrimPrefix(line, "use "))
+		}
+
+		if usePath == "" {
+			continue
+		}
+		if strings.HasPrefix(usePath, "\"") || strings.HasPrefix(usePath, "`") {
+			unquoted, errUnquote := strconv.Unquote(usePath)
+			if errUnquote != nil {
+				return []string{}, fmt.Errorf("Error: Can not read the path '%s' in line %d of '%s'. %w", usePath, i+1, filepath.Join(workspaceDir, "go.work"), errUnquote)
+			}
+			usePath = unquoted
+		}
+
+		moduleDir := filepath.FromSlash(usePath)
+		if !filepath.IsAbs(moduleDir) {
+			moduleDir = filepath.Join(workspaceDir, moduleDir)
+		}
+		if !listContains(modules, moduleDir) {
+			modules = append(modules, moduleDir)
+		}
+	}
+	sort.Strings(modules)
+
+	return modules, nil
+}
+
+// FindPackagesToBuildInWorkspace - Find a list of folders that contain go packages, respecting a 'go.work' file
+// If sourceDir contains a 'go.work' file, exactly the modules of its 'use' directives are returned, like the go tool sees them.
+// Otherwise the result of FindPackagesToBuild is returned
+// - sourceDir: The directory this function will start to search in. Usually the repository root directory
+// It returns the list of directory paths and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindPackagesToBuildInWorkspace(sourceDir string) ([]string, error) {
+	if !PathExists(filepath.Join(sourceDir, "go.work")) {
+		return FindPackagesToBuild(sourceDir)
+	}
+
+	return FindWorkspaceModules(sourceDir)
+}
+
+// FindPackagesToTestInWorkspace - Find a list of folders that contain go packages with tests, respecting a 'go.work' file
+// If sourceDir contains a 'go.work' file, only the modules of its 'use' directives are searched. Like the go tool does for './...',
+// folders named 'testdata' or 'vendor', folders starting with '.' or '_' and nested modules are skipped then.
+// Otherwise the result of FindPackagesToTest is returned
+// - sourceDir: The directory this function will start to search in. Usually the repository root directory
+// It returns the list of directory paths and nil in case of no error
+// If an error occur the error and an empty list will be returned
+func FindPackagesToTestInWorkspace(sourceDir string) ([]string, error) {
+	if !PathExists(filepath.Join(sourceDir, "go.work")) {
+		return FindPackagesToTest(sourceDir)
+	}
+
+	modules, errModules := FindWorkspaceModules(sourceDir)
+	if errModules != nil {
+		return []string{}, errModules
+	}
+
+	packagesToTest := []string{}
+	for _, module := range modules {
+		errFindTest := filepath.Walk(module, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				name := info.Name()
+				if path != module && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || PathExists(filepath.Join(path, "go.mod"))) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			packToTest := filepath.Dir(path)
+			if strings.HasSuffix(path, "_test.go") && !listContains(packagesToTest, packToTest) {
+				packagesToTest = append(packagesToTest, packToTest)
+			}
+
+			return nil
+		})
+		if errFindTest != nil {
+			return []string{}, errFindTest
+		}
+	}
+	sort.Strings(packagesToTest)
+
+	return packagesToTest, nil
+}
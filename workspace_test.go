@@ -0,0 +1,107 @@
+// Copyright 2022 by tobi@backfrak.de. All
+// rights reserved. Use of this source code is governed
+// by a BSD-style license that can be found in the
+// LICENSE file.
+
+package gobuildhelpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFindWorkspaceModules(t *testing.T) {
+	workspaceDir := filepath.Join(".", "testdata", "testWorkspace")
+	modules, err := FindWorkspaceModules(workspaceDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	expected := []string{filepath.Join(workspaceDir, "modA"), filepath.Join(workspaceDir, "modB")}
+	if strings.Join(modules, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the modules '%s', but got '%s'", expected, modules)
+	}
+
+	modules, err = FindWorkspaceModules(filepath.Join(".", "testdata", "testProject"))
+	if err == nil {
+		t.Errorf("Got no error, but expected one")
+	}
+	if len(modules) != 0 {
+		t.Errorf("Expected '0' modules, but got '%d'", len(modules))
+	}
+}
+
+func TestFindWorkspaceModulesWithAbsolutePath(t *testing.T) {
+	RemovePaths([]string{baseDir})
+	if err := EnsureDirectoryExists(baseDir); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	absModuleDir, errAbs := filepath.Abs(filepath.Join(".", "testdata", "testWorkspace", "modA"))
+	if errAbs != nil {
+		t.Errorf("Got error '%s', but expected none", errAbs.Error())
+	}
+	content := fmt.Sprintf("go 1.18\n\nuse (\n\t./modB\n\t%s\n)\n", strconv.Quote(filepath.ToSlash(absModuleDir)))
+	if err := os.WriteFile(filepath.Join(baseDir, "go.work"), []byte(content), 0644); err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+
+	modules, err := FindWorkspaceModules(baseDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if !listContains(modules, absModuleDir) || !listContains(modules, filepath.Join(baseDir, "modB")) || len(modules) != 2 {
+		t.Errorf("Expected the modules '%s' and '%s', but got '%s'", absModuleDir, filepath.Join(baseDir, "modB"), modules)
+	}
+
+	RemovePaths([]string{baseDir})
+}
+
+func TestFindPackagesToBuildInWorkspace(t *testing.T) {
+	workspaceDir := filepath.Join(".", "testdata", "testWorkspace")
+	dirs, err := FindPackagesToBuildInWorkspace(workspaceDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(dirs) != 2 {
+		t.Errorf("Expected '2' folders to build, but got '%d'", len(dirs))
+	}
+
+	dirs, err = FindPackagesToBuild(workspaceDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(dirs) != 4 {
+		t.Errorf("Expected '4' folders to build without the workspace, but got '%d'", len(dirs))
+	}
+
+	dirs, err = FindPackagesToBuildInWorkspace(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(dirs) != 1 {
+		t.Errorf("Expected '1' folder to build, but got '%d'", len(dirs))
+	}
+}
+
+func TestFindPackagesToTestInWorkspace(t *testing.T) {
+	workspaceDir := filepath.Join(".", "testdata", "testWorkspace")
+	dirs, err := FindPackagesToTestInWorkspace(workspaceDir)
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(dirs) != 1 || dirs[0] != filepath.Join(workspaceDir, "modA") {
+		t.Errorf("Expected only '%s' to test, but got '%s'", filepath.Join(workspaceDir, "modA"), dirs)
+	}
+
+	dirs, err = FindPackagesToTestInWorkspace(filepath.Join(".", "testdata", "testProject"))
+	if err != nil {
+		t.Errorf("Got error '%s', but expected none", err.Error())
+	}
+	if len(dirs) != 1 {
+		t.Errorf("Expected '1' folder to test, but got '%d'", len(dirs))
+	}
+}